@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"casl/controller"
+	"casl/entities"
+	"casl/history"
+	"casl/input"
+	"casl/requests"
+)
+
+var checkCmd = &command{
+	name:  "check",
+	args:  "file... (- for the standard input)",
+	short: "compare SUDOC and Alma locations of the PPNs listed in the files",
+	setup: func(fs *flag.FlagSet) func(args []string, stdout, stderr io.Writer) error {
+		opts := checkOptions{config: configFlag(fs)}
+		opts.output = outputFlags(fs)
+		opts.previous = fs.String("compare", "", "previous results file (csv, json or jsonl) to compare with")
+		opts.history = fs.String("history", "", "SQLite database where the run is recorded")
+		opts.column = fs.String("column", "", "name or position (from 1) of the PPN column, for CSV or TSV files with a header")
+		opts.marc = fs.Bool("marc", false, "files are MARC records (ISO 2709 or MARCXML): PPNs are read from 001 or 035$a")
+		opts.filtered = fs.Bool("filtered", false, "list the locations which were not checked, and why")
+		opts.quality = fs.Bool("quality", false, "report the structural problems of the SUDOC records")
+		opts.deep = fs.Bool("deep", false, "fetch the full Alma records and report those describing another work than the SUDOC record")
+		return func(args []string, stdout, stderr io.Writer) error { return check(opts, args, stdout, stderr) }
+	},
+}
+
+type checkOptions struct {
+	config   *string
+	output   outputOptions
+	previous *string
+	history  *string
+	filtered *bool
+	quality  *bool
+	deep     *bool
+	column   *string
+	marc     *bool
+}
+
+// outputOptions are the flags telling how results are written.
+type outputOptions struct {
+	format  *string
+	groupBy *string
+	split   *bool
+	dir     *string
+}
+
+func outputFlags(fs *flag.FlagSet) outputOptions {
+	return outputOptions{
+		format: fs.String("format", controller.FormatCSV,
+			"output format: "+strings.Join(controller.Formats, ", ")),
+		groupBy: fs.String("group", controller.GroupByILN,
+			"split xlsx sheets or result files by: "+strings.Join(controller.GroupKeys, ", ")),
+		split: fs.Bool("split", false, "write one result file per group and an index"),
+		dir:   fs.String("outdir", "", "directory where results are written"),
+	}
+}
+
+// validate checks the flags and creates the output directory, so that a run
+// does not fail once every PPN is fetched.
+func (o outputOptions) validate() error {
+	if !slices.Contains(controller.Formats, *o.format) {
+		return fmt.Errorf("unknown output format %q: %w", *o.format, errUsage)
+	}
+	if !slices.Contains(controller.GroupKeys, *o.groupBy) {
+		return fmt.Errorf("unknown grouping key %q: %w", *o.groupBy, errUsage)
+	}
+	if *o.dir != "" {
+		if err := os.MkdirAll(*o.dir, 0755); err != nil {
+			return fmt.Errorf("output directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// write writes the results with the controller, as requested by the flags.
+func (o outputOptions) write(ctrl *controller.Controller, results []controller.Summary) (string, error) {
+	ctrl.Output.GroupBy = *o.groupBy
+	ctrl.Output.Dir = *o.dir
+	if *o.split {
+		return ctrl.WriteSplitResults(results, *o.format)
+	}
+	return ctrl.WriteResults(results, *o.format)
+}
+
+func check(opts checkOptions, args []string, stdout, stderr io.Writer) error {
+	logger := newLogger(stderr)
+	if len(args) < 1 {
+		return fmt.Errorf("no PPN file: %w", errUsage)
+	}
+	if *opts.marc && *opts.column != "" {
+		return fmt.Errorf("-column does not apply to MARC files: %w", errUsage)
+	}
+	if err := opts.output.validate(); err != nil {
+		return err
+	}
+	var previousResults []controller.Summary
+	if *opts.previous != "" {
+		var err error
+		previousResults, err = controller.ReadResults(*opts.previous)
+		if err != nil {
+			return err
+		}
+	}
+
+	records, err := readPPNs(logger, args, *opts.column, *opts.marc)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	fetcher := requests.NewHttpFetch(nil)
+	ctrl, err := controller.NewController(*opts.config, fetcher)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%d PPN à vérifier...\n", len(records))
+
+	var findings []controller.QualityFinding
+	var mismatches []controller.BibMismatch
+	untracked := 0
+	ctrl.BeforeFetch = func(i int, ppn string) {
+		fmt.Fprintf(stdout, "ppn %d/%d...\n", i, len(records))
+	}
+	// The quality and deep checks of a record use the requests context of Run,
+	// so that they complete when the run is interrupted.
+	ctrl.OnRecord = func(ctx context.Context, i int, record entities.BibRecord, err error) {
+		if *opts.quality {
+			// Records whose locations cannot be read are validated too.
+			f, err := ctrl.ValidateRecord(ctx, record)
+			if err != nil {
+				logger.Println(err)
+			}
+			findings = append(findings, f...)
+		}
+		if err != nil {
+			logger.Println(err)
+			return
+		}
+		for _, loc := range controller.Untracked(record) {
+			logger.Printf("ppn %s: RCR %s (ILN %q) is mapped to Alma but its ILN is not tracked", record.PPN, loc.RCR, loc.ILN)
+			untracked++
+		}
+		if *opts.deep {
+			m, err := ctrl.CompareBib(ctx, record)
+			if err != nil {
+				logger.Println(err)
+			} else if m != nil {
+				mismatches = append(mismatches, *m)
+			}
+		}
+	}
+
+	ppns := make([]string, len(records))
+	for i, record := range records {
+		ppns[i] = record.PPN
+	}
+	ctx, stop := interruptContext(logger)
+	defer stop()
+	res, err := ctrl.Run(ctx, ppns)
+	var interrupted error
+	if errors.Is(err, context.Canceled) {
+		// The results of the PPNs checked so far are written.
+		done := len(res.Records) + len(res.Skipped) + len(res.Errors)
+		interrupted = fmt.Errorf("interrupted after %d of %d PPNs", done, len(ppns))
+		records = records[:done]
+		fmt.Fprintf(stdout, "Interruption : %d PPN vérifiés sur %d\n", done, len(ppns))
+	} else if err != nil {
+		return err
+	}
+	results, sums := res.Records, res.Anomalies
+
+	if skipped := len(res.Skipped); skipped > 0 {
+		fmt.Fprintf(stdout, "%d notice(s) non vérifiée(s) (supprimées ou d'un type non suivi)\n", skipped)
+	}
+	if untracked > 0 {
+		fmt.Fprintf(stdout, "%d localisation(s) SUDOC d'un ILN non suivi mais présentes dans la correspondance (voir -filtered)\n", untracked)
+	}
+
+	filename, err := opts.output.write(&ctrl, sums)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Résultats : %s\n", filename)
+
+	if *opts.filtered {
+		filename, err := ctrl.WriteFiltered(results)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Localisations écartées : %s\n", filename)
+	}
+
+	if *opts.deep {
+		filename, err := ctrl.WriteBibMismatches(mismatches)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%d notice(s) Alma décrivant une autre œuvre : %s\n", len(mismatches), filename)
+	}
+
+	if *opts.quality {
+		filename, err := ctrl.WriteQuality(findings)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%d problème(s) de structure des notices : %s\n", len(findings), filename)
+	}
+
+	// Exceptions of the PPNs which were not checked would be reported as
+	// stale.
+	if interrupted != nil {
+		fmt.Fprintln(stdout, "Recherche des exceptions obsolètes ignorée : exécution interrompue")
+	} else if stale := ctrl.StaleExceptions(); len(stale) > 0 {
+		filename, err := ctrl.WriteStaleExceptions(stale)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%d exception(s) obsolète(s) : %s\n", len(stale), filename)
+	}
+
+	if *opts.previous != "" && interrupted != nil {
+		fmt.Fprintln(stdout, "Comparaison avec l'exécution précédente ignorée : exécution interrompue")
+	} else if *opts.previous != "" {
+		diff := controller.CompareRuns(previousResults, sums)
+		diffFormat := *opts.output.format
+		if diffFormat == controller.FormatHTML {
+			diffFormat = controller.FormatCSV
+		}
+		filename, err := ctrl.WriteDiff(diff, diffFormat)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Comparaison avec %s (%s) : %s\n", *opts.previous, diff, filename)
+	}
+
+	if *opts.history != "" && interrupted != nil {
+		fmt.Fprintln(stdout, "Exécution non enregistrée dans l'historique : exécution interrompue")
+	} else if *opts.history != "" {
+		if err := saveRun(stdout, *opts.history, &ctrl, start, records, results, sums); err != nil {
+			return err
+		}
+	}
+
+	elapsed := time.Since(start)
+	fmt.Fprintf(stdout, "Elapsed time: %s\n", elapsed)
+
+	fmt.Fprintln(stdout)
+	fmt.Fprintln(stdout, "ALMA STATS")
+	fmt.Fprintf(stdout, "bibs: %d\n", ctrl.AlmaClient.Stats("bibs"))
+	fmt.Fprintf(stdout, "items: %d\n", ctrl.AlmaClient.Stats("items"))
+	fmt.Fprintf(stdout, "total: %d\n", ctrl.AlmaClient.Stats("total"))
+	fmt.Fprintln(stdout)
+	fmt.Fprintln(stdout, "SUDOC STATS")
+	fmt.Fprintf(stdout, "iln2rcr: %d\n", ctrl.SUClient.Stats("iln2rcr"))
+	fmt.Fprintf(stdout, "rcr2iln: %d\n", ctrl.SUClient.Stats("rcr2iln"))
+	fmt.Fprintf(stdout, "marcxml: %d\n", ctrl.SUClient.Stats("marcxml"))
+	fmt.Fprintf(stdout, "total: %d\n", ctrl.SUClient.Stats("total"))
+	return interrupted
+}
+
+// interruptContext returns a context canceled by the first SIGINT or SIGTERM,
+// so that the run stops and writes its results. The signals then get their
+// default behavior: a second one terminates the process.
+func interruptContext(logger *log.Logger) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			logger.Printf("%s: stopping after the current PPN (again to quit now)", sig)
+			signal.Stop(signals)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// readPPNs reads the PPNs to check from the files, or from the standard input
+// for "-". Invalid PPNs are discarded.
+// With isMARC, the files are MARC records instead of lists.
+func readPPNs(logger *log.Logger, filenames []string, column string, isMARC bool) ([]entities.BibRecord, error) {
+	read := func(r io.Reader) (input.Result, error) {
+		if isMARC {
+			return input.ReadMARC(r)
+		}
+		return input.Read(r, column)
+	}
+	var records []entities.BibRecord
+	for _, filename := range filenames {
+		var res input.Result
+		var err error
+		if filename == "-" {
+			res, err = read(os.Stdin)
+		} else {
+			res, err = readPPNFile(filename, read)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		for _, ppn := range res.PPNs {
+			records = append(records, entities.BibRecord{PPN: ppn})
+		}
+		for _, value := range res.Invalid {
+			logger.Printf("invalid PPN: %s", value)
+		}
+	}
+	return records, nil
+}
+
+func readPPNFile(filename string, read func(io.Reader) (input.Result, error)) (input.Result, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return input.Result{}, err
+	}
+	defer f.Close()
+	return read(f)
+}
+
+// saveRun records the run in the history database.
+func saveRun(stdout io.Writer, path string, ctrl *controller.Controller, start time.Time, checked, records []entities.BibRecord, sums []controller.Summary) error {
+	store, err := history.Open(path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	config, err := ctrl.ConfigSnapshot()
+	if err != nil {
+		return err
+	}
+	run := history.Run{
+		StartedAt:  start,
+		FinishedAt: time.Now(),
+		Config:     config,
+		Records:    records,
+		Anomalies:  sums,
+		Stats: map[string]int{
+			"alma_bibs":     ctrl.AlmaClient.Stats("bibs"),
+			"alma_items":    ctrl.AlmaClient.Stats("items"),
+			"sudoc_iln2rcr": ctrl.SUClient.Stats("iln2rcr"),
+			"sudoc_marcxml": ctrl.SUClient.Stats("marcxml"),
+		},
+	}
+	for _, record := range checked {
+		run.PPNs = append(run.PPNs, record.PPN)
+	}
+	if err := store.SaveRun(&run); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Exécution enregistrée dans %s (n° %d)\n", path, run.ID)
+	return nil
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"casl/entities"
+	"casl/marc"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BibMismatch is an Alma record linked to a PPN which describes another work
+// than the SUDOC record.
+type BibMismatch struct {
+	PPN string
+	MMS string
+	// Differences are the compared elements which differ: "title", "isbn",
+	// "date" or "035".
+	Differences []string
+	Sudoc       marc.Keys
+	Alma        marc.Keys
+}
+
+var bibMismatchHeader = []string{"ppn", "mms", "differences", "sudoc_title", "alma_title",
+	"sudoc_isbn", "alma_isbn", "sudoc_date", "alma_date", "sudoc_035", "alma_035", "alma_url"}
+
+// CompareBib fetches the full Alma record of a fetched record and compares its
+// title, ISBNs, publication date and 035 identifiers with the SUDOC record,
+// which is fetched again only if the record does not hold it. It returns nil
+// if both describe the same work, or if the PPN is not in Alma.
+func (ctrl *Controller) CompareBib(ctx context.Context, record entities.BibRecord) (*BibMismatch, error) {
+	if record.MMS == "" {
+		return nil, nil
+	}
+	sudoc, err := ctrl.sudocRecord(ctx, record)
+	if err != nil {
+		return nil, fmt.Errorf("CompareBib: %w", err)
+	}
+	alma, err := ctrl.AlmaClient.GetBibContext(ctx, record.MMS)
+	if err != nil {
+		return nil, fmt.Errorf("CompareBib: mms %s: %w", record.MMS, err)
+	}
+	m := BibMismatch{PPN: record.PPN, MMS: record.MMS, Sudoc: sudoc.Keys(), Alma: alma.Keys()}
+	m.Differences = m.Sudoc.Differences(m.Alma)
+	if len(m.Differences) == 0 {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// WriteBibMismatches writes the mismatched records into a CSV file in the
+// output directory and returns its name. Multiple values are separated by "|".
+func (ctrl *Controller) WriteBibMismatches(mismatches []BibMismatch) (string, error) {
+	rows := [][]string{bibMismatchHeader}
+	for _, m := range mismatches {
+		rows = append(rows, []string{m.PPN, m.MMS, strings.Join(m.Differences, "|"),
+			m.Sudoc.Title, m.Alma.Title,
+			strings.Join(m.Sudoc.ISBNs, "|"), strings.Join(m.Alma.ISBNs, "|"),
+			m.Sudoc.Date, m.Alma.Date,
+			strings.Join(m.Sudoc.IDs, "|"), strings.Join(m.Alma.IDs, "|"),
+			ctrl.almaURL(m.MMS)})
+	}
+
+	filename := filepath.Join(ctrl.Output.Dir,
+		"notices"+strings.TrimPrefix(resultsFilename(time.Now(), FormatCSV), "resultats"))
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", fmt.Errorf("WriteBibMismatches: %w", err)
+	}
+	defer f.Close()
+	if err := csv.NewWriter(f).WriteAll(rows); err != nil {
+		return "", fmt.Errorf("WriteBibMismatches: %w", err)
+	}
+	return filename, f.Close()
+}
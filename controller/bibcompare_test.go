@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"casl/entities"
+	"casl/marc"
+	"context"
+	"encoding/csv"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestCompareBib(t *testing.T) {
+	bib := func(title, isbn string) *marc.Record {
+		return &marc.Record{
+			Leader: "     nam0 22        450 ",
+			Datafields: []marc.Datafield{
+				{Tag: "010", Subfields: []marc.Subfield{{Code: "a", Value: isbn}}},
+				{Tag: "200", Subfields: []marc.Subfield{{Code: "a", Value: title}}},
+			},
+		}
+	}
+	ctrl := Controller{
+		Config:     &Config{AlmaBibURL: "https://alma/{mms}"},
+		SUClient:   fakeSudoc{record: bib("L'étranger", "2-07-036002-4")},
+		AlmaClient: fakeAlma{bib: bib("L'Étranger", "9782070360024")},
+		Output:     OutputOptions{Dir: t.TempDir()},
+	}
+	record := entities.BibRecord{PPN: "123456789", MMS: "99123"}
+	if m, err := ctrl.CompareBib(context.Background(), record); err != nil || m != nil {
+		t.Fatalf("same work: want no mismatch, got %v, %v", m, err)
+	}
+	if m, err := ctrl.CompareBib(context.Background(), entities.BibRecord{PPN: "123456789"}); err != nil || m != nil {
+		t.Fatalf("no MMS: want no mismatch, got %v, %v", m, err)
+	}
+
+	ctrl.AlmaClient = fakeAlma{bib: bib("La peste", "9782070360420")}
+	m, err := ctrl.CompareBib(context.Background(), record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || !slices.Equal(m.Differences, []string{"title", "isbn"}) {
+		t.Fatalf("want title and isbn differences, got %+v", m)
+	}
+
+	filename, err := ctrl.WriteBibMismatches([]BibMismatch{*m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1][2] != "title|isbn" || rows[1][4] != "la peste" || rows[1][11] != "https://alma/99123" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
@@ -2,15 +2,14 @@ package controller
 
 import (
 	"casl/entities"
-	"casl/exl"
+	"casl/marc"
 	"casl/requests"
-	"casl/sudoc"
-	"encoding/csv"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"os"
+	"path/filepath"
 	"slices"
 	"time"
 )
@@ -18,40 +17,58 @@ import (
 // NewController creates a fully self-configured controller, which is the entry
 // point of the process.
 func NewController(configFile string, fetcher requests.Fetcher) (Controller, error) {
-	var ctrl Controller
-
-	ctrl.loadConfig(configFile)
-	ctrl.getMappingsFromCSV(ctrl.Config.MappingFilePath)
-	ctrl.getLibs()
-
-	suclient, err := sudoc.NewSudocClient(ctrl.Config.ILNs, fetcher)
+	ctrl, err := New(WithConfigFile(configFile), WithFetcher(fetcher))
 	if err != nil {
-		return ctrl, err
+		return Controller{}, err
 	}
-	ctrl.SUClient = suclient
-	ctrl.Config.FollowedRCR = ctrl.SUClient.GetFollowedRCRs()
+	return *ctrl, nil
+}
 
-	ctrl.AlmaClient, err = exl.NewAlmaClient(ctrl.Config.AlmaAPIKey, "", fetcher)
+// NewMappingController creates a controller with its clients to work on the
+// alma-rcr mapping, which may not exist yet. Exceptions are not loaded.
+func NewMappingController(configFile string, fetcher requests.Fetcher) (Controller, error) {
+	ctrl, err := New(WithConfigFile(configFile), withOptionalMappings(), withoutExceptions(), WithFetcher(fetcher))
 	if err != nil {
-		return ctrl, err
+		return Controller{}, err
 	}
+	return *ctrl, nil
+}
 
-	return ctrl, nil
+// NewOfflineController creates a controller from the configuration, the
+// mappings and the exceptions only. It has no client: it can write reports
+// but not fetch locations.
+func NewOfflineController(configFile string) (Controller, error) {
+	ctrl, err := New(WithConfigFile(configFile))
+	if err != nil {
+		return Controller{}, err
+	}
+	return *ctrl, nil
 }
 
-func (ctrl *Controller) loadConfig(configFile string) {
-	var conf config
+// loadConfig reads the JSON configuration file. Relative paths of the files it
+// refers to are resolved from the directory of the configuration file.
+func (ctrl *Controller) loadConfig(configFile string) error {
+	var conf Config
 	content, err := os.ReadFile(configFile)
 	if err != nil {
-		log.Fatalf("loadConfig: %s", err)
+		return fmt.Errorf("loadConfig: %w", err)
 	}
 
 	err = json.Unmarshal(content, &conf)
 	if err != nil {
-		log.Fatalf("loadConfig: %s", err)
+		return fmt.Errorf("loadConfig: %s: %w", configFile, err)
+	}
+
+	dir := filepath.Dir(configFile)
+	if conf.MappingFilePath != "" && !filepath.IsAbs(conf.MappingFilePath) {
+		conf.MappingFilePath = filepath.Join(dir, conf.MappingFilePath)
+	}
+	if conf.ExceptionsFile != "" && !filepath.IsAbs(conf.ExceptionsFile) {
+		conf.ExceptionsFile = filepath.Join(dir, conf.ExceptionsFile)
 	}
 
 	ctrl.Config = &conf
+	return nil
 }
 
 func (ctrl *Controller) getLibs() {
@@ -64,82 +81,155 @@ func (ctrl *Controller) getLibs() {
 	ctrl.Config.FolowedLibs = libs
 }
 
-// The CSV alma-rcr mapping should be formatted as follows :
-// "Library name","Library code",RCR,ILN
-func (ctrl *Controller) getMappingsFromCSV(csv_file string) {
-	var maps mappings
-	maps.alma2rcr = make(map[string][]string)
-	maps.rcr2alma = make(map[string][]string)
-	maps.rcr2iln = make(map[string]string)
-	maps.alma2str = make(map[string]string)
-	maps.rcr2str = make(map[string]string)
-	f, err := os.Open(csv_file)
+// ErrSkippedRecord is returned by Fetch for a record which must not be
+// checked: deleted, or excluded by its type.
+var ErrSkippedRecord = errors.New("record skipped")
+
+// Fetch gets all the locations of a PPN from both clients and filters them.
+// The discarded locations are kept in the Filtered field of the record, with
+// the reasons why they are not checked.
+func (ctrl *Controller) Fetch(ppn string) (entities.BibRecord, error) {
+	return ctrl.FetchContext(context.Background(), ppn)
+}
+
+// FetchContext is Fetch with a context, which interrupts the requests when it
+// is done.
+func (ctrl *Controller) FetchContext(ctx context.Context, ppn string) (entities.BibRecord, error) {
+	record := entities.BibRecord{PPN: ppn}
+	sudocRecord, err := ctrl.SUClient.GetRecordContext(ctx, ppn)
+	if err != nil {
+		return record, err
+	}
+	record.Sudoc = sudocRecord
+	if reason := ctrl.skipReason(sudocRecord); reason != "" {
+		return record, fmt.Errorf("ppn %s: %w: %s", ppn, ErrSkippedRecord, reason)
+	}
+	record.SudocLocations, err = ctrl.SUClient.RecordLocationsContext(ctx, ppn, sudocRecord)
+	if err != nil {
+		return record, err
+	}
+	record.AlmaLocations, err = ctrl.AlmaClient.GetLocationsContext(ctx, ppn)
 	if err != nil {
-		log.Fatal(err)
+		return record, err
 	}
-	defer f.Close()
+	if len(record.AlmaLocations) > 0 {
+		record.MMS = record.AlmaLocations[0].MMS
+	}
+	record.FetchedAt = time.Now()
+	ctrl.filter(&record)
+	return record, nil
+}
 
-	r := csv.NewReader(f)
-	for {
-		record, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatal(err)
-		}
-		maps.alma2rcr[record[1]] = append(maps.alma2rcr[record[1]], record[2])
-		maps.rcr2alma[record[2]] = append(maps.rcr2alma[record[2]], record[1])
-		maps.rcr2iln[record[2]] = record[3]
-		maps.alma2str[record[1]] = record[0]
+// skipReason tells why a SUDOC record must not be checked, or returns an empty
+// string.
+func (ctrl *Controller) skipReason(record *marc.Record) string {
+	leader := record.GetLeader()
+	switch {
+	case leader.IsDeleted():
+		return "notice supprimée"
+	case len(ctrl.Config.BibLevels) > 0 &&
+		!slices.Contains(ctrl.Config.BibLevels, string(leader.BibliographicLevel())):
+		return fmt.Sprintf("niveau bibliographique %q non suivi", leader.BibliographicLevel())
+	case ctrl.Config.SkipElectronic && record.IsElectronic():
+		return "ressource électronique"
 	}
-	ctrl.Mappings = &maps
+	return ""
 }
 
+// AnomalyType tells on which side a location is missing.
+type AnomalyType string
+
+const (
+	// MissingInAlma means the location exists in SUDOC but not in Alma.
+	MissingInAlma AnomalyType = "missing_in_alma"
+	// MissingInSudoc means the location exists in Alma but not in SUDOC.
+	MissingInSudoc AnomalyType = "missing_in_sudoc"
+)
+
 // Summary represents the informations necessary to identify an anomaly, ie a
 // record for which alma locations and sudoc locations are not matching.
+// The JSON field names are part of the output schema documented in the README
+// and must not be changed.
 type Summary struct {
-	ILN      string
-	RCR      string
-	PPN      string
-	SudocLib string
-	AlmaLib  string
+	Type         AnomalyType `json:"type"`
+	ILN          string      `json:"iln"`
+	RCR          string      `json:"rcr"`
+	PPN          string      `json:"ppn"`
+	MMS          string      `json:"mms"`
+	EPN          string      `json:"epn"`
+	SudocLib     string      `json:"sudoc_library"`
+	AlmaLib      string      `json:"alma_library"`
+	AlmaLibCode  string      `json:"alma_library_code"`
+	AlmaLocation string      `json:"alma_location_code"`
+	CallNumber   string      `json:"call_number"`
+	CheckedAt    time.Time   `json:"checked_at"`
+	// Acknowledged is set when the anomaly matches an exception, in "tag"
+	// mode. Comment is then the comment of the exception.
+	Acknowledged bool   `json:"acknowledged"`
+	Comment      string `json:"comment"`
 }
 
 // Compare looks for anomalies - ie locations not maching - in the provided
-// bib records.
+// bib records. Anomalies matching an exception are removed or tagged.
 func (ctrl *Controller) Compare(record *entities.BibRecord) []Summary {
 	var anomalies []Summary
 
 MAIN_SU_LOOP:
 	for _, sloc := range record.SudocLocations {
-		almaLibs := ctrl.Mappings.rcr2alma[sloc.RCR]
 		for _, aloc := range record.AlmaLocations {
-			if slices.Contains(almaLibs, aloc.Library_code) {
+			if slices.Contains(ctrl.Mappings.rcrs(aloc), sloc.RCR) {
 				continue MAIN_SU_LOOP
 			}
 		}
-		// TODO: test that
-		//library := ctrl.Mappings.rcr2str[sloc.RCR]
-		library := sloc.Name
+		library := ctrl.Mappings.rcr2str[sloc.RCR]
+		if library == "" {
+			library = sloc.Name
+		}
 		if slices.Contains(ctrl.Config.MonolithicRCR, sloc.RCR) && sloc.Sublocation != "" {
 			library += " - " + sloc.Sublocation
 		}
-		anomalies = append(anomalies, Summary{ILN: sloc.ILN, RCR: sloc.RCR, PPN: record.PPN, SudocLib: library, AlmaLib: ""})
+		anomalies = append(anomalies, Summary{
+			Type:       MissingInAlma,
+			ILN:        sloc.ILN,
+			RCR:        sloc.RCR,
+			PPN:        record.PPN,
+			MMS:        record.MMS,
+			EPN:        sloc.EPN,
+			SudocLib:   library,
+			CallNumber: sloc.CallNumber,
+			CheckedAt:  record.FetchedAt,
+		})
 	}
 
 MAIN_ALMA_LOOP:
 	for _, aloc := range record.AlmaLocations {
-		rcrs := ctrl.Mappings.alma2rcr[aloc.Library_code]
+		rcrs := ctrl.Mappings.rcrs(aloc)
 		for _, sloc := range record.SudocLocations {
 			if slices.Contains(rcrs, sloc.RCR) {
 				continue MAIN_ALMA_LOOP
 			}
 		}
-		anomalies = append(anomalies, Summary{ILN: ctrl.Mappings.rcr2iln[rcrs[0]], RCR: rcrs[0], PPN: record.PPN, SudocLib: "", AlmaLib: ctrl.Mappings.alma2str[aloc.Library_code]})
+		// A library mapped at location level only has no RCR for its other
+		// locations.
+		var rcr string
+		if len(rcrs) > 0 {
+			rcr = rcrs[0]
+		}
+		anomalies = append(anomalies, Summary{
+			Type:         MissingInSudoc,
+			ILN:          ctrl.Mappings.rcr2iln[rcr],
+			RCR:          rcr,
+			PPN:          record.PPN,
+			MMS:          aloc.MMS,
+			AlmaLib:      ctrl.Mappings.alma2str[aloc.Library_code],
+			AlmaLibCode:  aloc.Library_code,
+			AlmaLocation: aloc.Location_code,
+			CallNumber:   aloc.Call_number,
+			CheckedAt:    record.FetchedAt,
+		})
 	}
 
-	return anomalies
+	return ctrl.applyExceptions(record.PPN, anomalies)
 }
 
 func (s Summary) toCSV() []string {
@@ -147,30 +237,18 @@ func (s Summary) toCSV() []string {
 	return records
 }
 
-// WriteCSV translates a list of Summaries into a CSV file.
-func (ctrl *Controller) WriteCSV(results []Summary) {
-	var records [][]string
-	records = append(records, []string{"PPN", "ILN", "Bibliothèque Alma",
-		"Bibliothèque SUDOC", "RCR"})
-
-	for _, res := range results {
-		records = append(records, res.toCSV())
-	}
-
-	t := time.Now()
-	format := fmt.Sprintf("%d%02d%02d-%02d%02d%02d", t.Year(), t.Month(), t.Day(),
-		t.Hour(), t.Minute(), t.Second())
-	filename := "resultats_" + format + ".csv"
-	f, err := os.Create(filename)
-	if err != nil {
-		log.Fatal("failed to open file", err)
-	}
-	defer f.Close()
-
-	w := csv.NewWriter(f)
-	err = w.WriteAll(records)
+// WriteCSV translates a list of Summaries into a CSV file, and returns its
+// name.
+func (ctrl *Controller) WriteCSV(results []Summary) (string, error) {
+	return ctrl.WriteResults(results, FormatCSV)
+}
 
-	if err != nil {
-		log.Fatal(err)
+// NewEmptyController creates a controller without configuration nor mappings,
+// to render reports when no configuration file is available. Library labels
+// are then missing.
+func NewEmptyController() Controller {
+	return Controller{
+		Config:   &Config{ExceptionsMode: ExceptionsSuppress},
+		Mappings: &Mappings{},
 	}
 }
@@ -0,0 +1,276 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Status of an anomaly compared to a previous run.
+const (
+	StatusNew        = "new"
+	StatusResolved   = "resolved"
+	StatusPersistent = "persistent"
+)
+
+// Diff sorts the anomalies of two runs: the ones which appeared, the ones
+// which were fixed and the ones which are still there.
+type Diff struct {
+	New        []Summary `json:"new"`
+	Resolved   []Summary `json:"resolved"`
+	Persistent []Summary `json:"persistent"`
+}
+
+// jsonDiff is the top-level document of the JSON comparison output.
+type jsonDiff struct {
+	SchemaVersion int       `json:"schema_version"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	Counts        struct {
+		New        int `json:"new"`
+		Resolved   int `json:"resolved"`
+		Persistent int `json:"persistent"`
+	} `json:"counts"`
+	Diff
+}
+
+// jsonDiffLine is a line of the JSON Lines comparison output.
+type jsonDiffLine struct {
+	Status string `json:"status"`
+	Summary
+}
+
+// Key identifies an anomaly across runs.
+func (s Summary) Key() string {
+	return s.PPN + "|" + s.RCR + "|" + string(s.Type)
+}
+
+// CompareRuns compares the anomalies of the current run to those of a previous
+// one, keyed by PPN, RCR and anomaly type.
+func CompareRuns(previous, current []Summary) Diff {
+	var diff Diff
+	before := make(map[string]bool)
+	for _, s := range previous {
+		before[s.Key()] = true
+	}
+	now := make(map[string]bool)
+	for _, s := range current {
+		now[s.Key()] = true
+		if before[s.Key()] {
+			diff.Persistent = append(diff.Persistent, s)
+		} else {
+			diff.New = append(diff.New, s)
+		}
+	}
+	for _, s := range previous {
+		if !now[s.Key()] {
+			diff.Resolved = append(diff.Resolved, s)
+		}
+	}
+	return diff
+}
+
+// ReadResults reads a results file written by a previous run, in CSV, JSON or
+// JSON Lines according to its extension.
+func ReadResults(filename string) ([]Summary, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("ReadResults: %w", err)
+	}
+	defer f.Close()
+
+	var results []Summary
+	switch strings.TrimPrefix(filepath.Ext(filename), ".") {
+	case FormatCSV:
+		results, err = decodeCSV(f)
+	case FormatJSON:
+		results, err = decodeJSON(f)
+	case FormatJSONL:
+		results, err = decodeJSONL(f)
+	default:
+		return nil, fmt.Errorf("ReadResults: %s: unsupported file type", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ReadResults: %s: %w", filename, err)
+	}
+	return results, nil
+}
+
+// decodeCSV reads the CSV output. As the anomaly type is not written, it is
+// deduced from the library column which is filled.
+func decodeCSV(r io.Reader) ([]Summary, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("empty file")
+	}
+	var results []Summary
+	for i, record := range records[1:] {
+		if len(record) != 5 {
+			return nil, fmt.Errorf("line %d: want 5 columns, got %d", i+2, len(record))
+		}
+		s := Summary{PPN: record[0], ILN: record[1], AlmaLib: record[2],
+			SudocLib: record[3], RCR: record[4], Type: MissingInAlma}
+		if s.AlmaLib != "" {
+			s.Type = MissingInSudoc
+		}
+		results = append(results, s)
+	}
+	return results, nil
+}
+
+func decodeJSON(r io.Reader) ([]Summary, error) {
+	var report jsonReport
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return nil, err
+	}
+	if report.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version %d", report.SchemaVersion)
+	}
+	return report.Anomalies, nil
+}
+
+func decodeJSONL(r io.Reader) ([]Summary, error) {
+	var results []Summary
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var s Summary
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		results = append(results, s)
+	}
+	return results, scanner.Err()
+}
+
+// WriteDiff writes the comparison in the given format into a new file named
+// after the current time, in the output directory, and returns its name.
+// HTML is not supported.
+func (ctrl *Controller) WriteDiff(diff Diff, format string) (string, error) {
+	var encode func(io.Writer, Diff) error
+	switch format {
+	case FormatCSV:
+		encode = encodeDiffCSV
+	case FormatJSON:
+		encode = encodeDiffJSON
+	case FormatJSONL:
+		encode = encodeDiffJSONL
+	case FormatXLSX:
+		encode = ctrl.encodeDiffXLSX
+	default:
+		return "", fmt.Errorf("WriteDiff: unsupported format %q", format)
+	}
+
+	filename := filepath.Join(ctrl.Output.Dir,
+		"comparaison"+strings.TrimPrefix(resultsFilename(time.Now(), format), "resultats"))
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", fmt.Errorf("WriteDiff: %w", err)
+	}
+	defer f.Close()
+	if err := encode(f, diff); err != nil {
+		return "", fmt.Errorf("WriteDiff: %w", err)
+	}
+	return filename, f.Close()
+}
+
+// diffSection is a list of anomalies sharing the same status.
+type diffSection struct {
+	status  string
+	label   string
+	results []Summary
+}
+
+// sections returns the lists of anomalies with their status and label.
+func (d Diff) sections() []diffSection {
+	return []diffSection{
+		{StatusNew, "Nouvelles", d.New},
+		{StatusResolved, "Corrigées", d.Resolved},
+		{StatusPersistent, "Persistantes", d.Persistent},
+	}
+}
+
+func encodeDiffCSV(w io.Writer, diff Diff) error {
+	records := [][]string{{"Statut", "PPN", "ILN", "Bibliothèque Alma",
+		"Bibliothèque SUDOC", "RCR"}}
+	for _, section := range diff.sections() {
+		for _, res := range section.results {
+			records = append(records, append([]string{section.status}, res.toCSV()...))
+		}
+	}
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+func encodeDiffJSON(w io.Writer, diff Diff) error {
+	report := jsonDiff{SchemaVersion: SchemaVersion, GeneratedAt: time.Now(), Diff: diff}
+	report.Counts.New = len(diff.New)
+	report.Counts.Resolved = len(diff.Resolved)
+	report.Counts.Persistent = len(diff.Persistent)
+	for _, list := range []*[]Summary{&report.New, &report.Resolved, &report.Persistent} {
+		if *list == nil {
+			*list = []Summary{}
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+func encodeDiffJSONL(w io.Writer, diff Diff) error {
+	enc := json.NewEncoder(w)
+	for _, section := range diff.sections() {
+		for _, res := range section.results {
+			if err := enc.Encode(jsonDiffLine{Status: section.status, Summary: res}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeDiffXLSX writes a summary sheet followed by one sheet per status.
+func (ctrl *Controller) encodeDiffXLSX(w io.Writer, diff Diff) error {
+	used := make(map[string]bool)
+	summary := xlsxSheet{name: xlsxSheetName("Synthèse", used)}
+	summary.rows = append(summary.rows, textCells("Anomalies", "Nombre"))
+	sheets := []xlsxSheet{summary}
+	for _, section := range diff.sections() {
+		sheets[0].rows = append(sheets[0].rows,
+			[]xlsxCell{{value: section.label}, numberCell(len(section.results))})
+		sheet := xlsxSheet{name: xlsxSheetName(section.label, used)}
+		sheet.rows = append(sheet.rows, textCells("Anomalie", "PPN", "ILN", "RCR",
+			"Bibliothèque SUDOC", "Bibliothèque Alma", "Cote", "MMS"))
+		for _, res := range section.results {
+			sheet.rows = append(sheet.rows, []xlsxCell{
+				{value: res.Type.Label()},
+				{value: res.PPN, link: sudocURL + res.PPN},
+				{value: res.ILN},
+				{value: res.RCR},
+				{value: res.SudocLib},
+				{value: res.AlmaLib},
+				{value: res.CallNumber},
+				{value: res.MMS, link: ctrl.almaURL(res.MMS)},
+			})
+		}
+		sheets = append(sheets, sheet)
+	}
+	return writeXLSX(w, sheets)
+}
+
+// String returns the number of anomalies of each status.
+func (d Diff) String() string {
+	return fmt.Sprintf("nouvelles : %d, corrigées : %d, persistantes : %d",
+		len(d.New), len(d.Resolved), len(d.Persistent))
+}
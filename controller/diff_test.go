@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompareRuns(t *testing.T) {
+	fixed := Summary{Type: MissingInAlma, PPN: "111111111", RCR: "100000001"}
+	kept := Summary{Type: MissingInSudoc, PPN: "222222222", RCR: "100000001"}
+	added := Summary{Type: MissingInAlma, PPN: "222222222", RCR: "100000001"}
+
+	got := CompareRuns([]Summary{fixed, kept}, []Summary{kept, added})
+	want := Diff{
+		New:        []Summary{added},
+		Resolved:   []Summary{fixed},
+		Persistent: []Summary{kept},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestReadResults(t *testing.T) {
+	results := provideSummaries()
+	dir := t.TempDir()
+
+	for _, format := range []string{FormatCSV, FormatJSON, FormatJSONL} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			ctrl := Controller{}
+			if err := ctrl.encode(&buf, results, format); err != nil {
+				t.Fatal(err)
+			}
+			filename := filepath.Join(dir, "results."+format)
+			if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadResults(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(results) {
+				t.Fatalf("want %d results, got %d", len(results), len(got))
+			}
+			for i := range got {
+				if got[i].Key() != results[i].Key() {
+					t.Errorf("want key %s, got %s", results[i].Key(), got[i].Key())
+				}
+			}
+		})
+	}
+
+	if _, err := ReadResults(filepath.Join(dir, "results.html")); err == nil {
+		t.Error("want error for unsupported file type")
+	}
+}
@@ -0,0 +1,234 @@
+package controller
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// What to do with the anomalies matching an exception.
+const (
+	ExceptionsSuppress = "suppress"
+	ExceptionsTag      = "tag"
+)
+
+// Exception is a known and accepted anomaly. Empty fields match any value.
+type Exception struct {
+	PPN     string
+	RCR     string
+	AlmaLib string
+	Type    AnomalyType
+	// Expires is the day after which the exception no longer applies. A zero
+	// value means it never expires.
+	Expires time.Time
+	Comment string
+	// Line is the line of the exceptions file.
+	Line int
+
+	matches int
+	checked bool
+}
+
+// StaleException is an exception which no longer matches anything.
+type StaleException struct {
+	Exception
+	Reason string
+}
+
+var exceptionsHeader = []string{"ppn", "rcr", "alma_library", "type", "expires", "comment"}
+
+// readExceptions reads the exceptions file, a CSV file whose header is:
+// ppn,rcr,alma_library,type,expires,comment
+// The expiry date is formatted as YYYY-MM-DD.
+func readExceptions(r io.Reader) ([]*Exception, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range exceptionsHeader {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("line 1: missing column %q", name)
+		}
+	}
+
+	var exceptions []*Exception
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		get := func(name string) string {
+			if i := columns[name]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		e := Exception{
+			PPN:     get("ppn"),
+			RCR:     get("rcr"),
+			AlmaLib: get("alma_library"),
+			Type:    AnomalyType(get("type")),
+			Comment: get("comment"),
+			Line:    line,
+		}
+		if e.PPN == "" && e.RCR == "" && e.AlmaLib == "" {
+			return nil, fmt.Errorf("line %d: at least one of ppn, rcr or alma_library is required", line)
+		}
+		if e.Type != "" && e.Type != MissingInAlma && e.Type != MissingInSudoc {
+			return nil, fmt.Errorf("line %d: unknown anomaly type %q", line, e.Type)
+		}
+		if expires := get("expires"); expires != "" {
+			e.Expires, err = time.ParseInLocation("2006-01-02", expires, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid expiry date %q", line, expires)
+			}
+		}
+		exceptions = append(exceptions, &e)
+	}
+	return exceptions, nil
+}
+
+// loadExceptions reads the exceptions file given in the configuration.
+func (ctrl *Controller) loadExceptions(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("loadExceptions: %w", err)
+	}
+	defer f.Close()
+	exceptions, err := readExceptions(f)
+	if err != nil {
+		return fmt.Errorf("loadExceptions: %s: %w", filename, err)
+	}
+	ctrl.Exceptions = exceptions
+	return nil
+}
+
+// expired reports whether the exception no longer applies at the given time.
+func (e *Exception) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && now.After(e.Expires.AddDate(0, 0, 1))
+}
+
+// match reports whether the exception applies to the anomaly.
+func (e *Exception) match(s Summary, almaLibs []string) bool {
+	if e.PPN != "" && e.PPN != s.PPN {
+		return false
+	}
+	if e.RCR != "" && e.RCR != s.RCR {
+		return false
+	}
+	if e.Type != "" && e.Type != s.Type {
+		return false
+	}
+	if e.AlmaLib != "" && e.AlmaLib != s.AlmaLibCode && !slices.Contains(almaLibs, e.AlmaLib) {
+		return false
+	}
+	return true
+}
+
+// applyExceptions removes or tags the anomalies matching an exception,
+// according to the configuration. ppn is the checked record.
+func (ctrl *Controller) applyExceptions(ppn string, anomalies []Summary) []Summary {
+	if len(ctrl.Exceptions) == 0 {
+		return anomalies
+	}
+	now := time.Now()
+	for _, e := range ctrl.Exceptions {
+		if e.PPN == ppn {
+			e.checked = true
+		}
+	}
+
+	var result []Summary
+	for _, anomaly := range anomalies {
+		var almaLibs []string
+		if anomaly.Type == MissingInAlma {
+			almaLibs = ctrl.Mappings.rcr2alma[anomaly.RCR]
+		}
+		var exception *Exception
+		for _, e := range ctrl.Exceptions {
+			if !e.expired(now) && e.match(anomaly, almaLibs) {
+				e.matches++
+				if exception == nil {
+					exception = e
+				}
+			}
+		}
+		if exception == nil {
+			result = append(result, anomaly)
+			continue
+		}
+		if ctrl.Config.ExceptionsMode == ExceptionsTag {
+			anomaly.Acknowledged = true
+			anomaly.Comment = exception.Comment
+			result = append(result, anomaly)
+		}
+	}
+	return result
+}
+
+// StaleExceptions returns the exceptions which are expired, and those which
+// did not match any anomaly although their PPN was checked. Exceptions without
+// PPN are stale if they did not match anything during the run.
+func (ctrl *Controller) StaleExceptions() []StaleException {
+	var stale []StaleException
+	now := time.Now()
+	for _, e := range ctrl.Exceptions {
+		switch {
+		case e.expired(now):
+			stale = append(stale, StaleException{*e, "expirée"})
+		case e.matches == 0 && (e.checked || e.PPN == ""):
+			stale = append(stale, StaleException{*e, "aucune anomalie correspondante"})
+		}
+	}
+	return stale
+}
+
+// WriteStaleExceptions writes the stale exceptions into a CSV file in the
+// output directory and returns its name.
+func (ctrl *Controller) WriteStaleExceptions(stale []StaleException) (string, error) {
+	if len(stale) == 0 {
+		return "", errors.New("WriteStaleExceptions: no stale exception")
+	}
+	records := [][]string{append([]string{"line"}, append(exceptionsHeader, "reason")...)}
+	for _, s := range stale {
+		expires := ""
+		if !s.Expires.IsZero() {
+			expires = s.Expires.Format("2006-01-02")
+		}
+		records = append(records, []string{fmt.Sprint(s.Line), s.PPN, s.RCR, s.AlmaLib,
+			string(s.Type), expires, s.Comment, s.Reason})
+	}
+
+	filename := filepath.Join(ctrl.Output.Dir,
+		"exceptions_obsoletes"+strings.TrimPrefix(resultsFilename(time.Now(), FormatCSV), "resultats"))
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", fmt.Errorf("WriteStaleExceptions: %w", err)
+	}
+	defer f.Close()
+	if err := csv.NewWriter(f).WriteAll(records); err != nil {
+		return "", fmt.Errorf("WriteStaleExceptions: %w", err)
+	}
+	return filename, f.Close()
+}
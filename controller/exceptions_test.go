@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const exceptionsCSV = `ppn,rcr,alma_library,type,expires,comment
+123456789,100000001,,missing_in_alma,,exemplaire de salle de lecture
+,200000001,BIB_1,missing_in_sudoc,2000-01-31,fusion de bibliothèques
+111111111,,,,,jamais vu
+,300000001,,,,RCR sans anomalie
+`
+
+func TestReadExceptions(t *testing.T) {
+	exceptions, err := readExceptions(strings.NewReader(exceptionsCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exceptions) != 4 {
+		t.Fatalf("want 4 exceptions, got %d", len(exceptions))
+	}
+	e := exceptions[1]
+	if e.RCR != "200000001" || e.AlmaLib != "BIB_1" || e.Type != MissingInSudoc ||
+		!e.Expires.Equal(time.Date(2000, 1, 31, 0, 0, 0, 0, time.Local)) || e.Line != 3 {
+		t.Errorf("unexpected exception %+v", e)
+	}
+
+	errorTests := []struct {
+		name  string
+		input string
+	}{
+		{"missing column", "ppn,rcr,type,expires,comment\n"},
+		{"no key", "ppn,rcr,alma_library,type,expires,comment\n,,,missing_in_alma,,\n"},
+		{"bad type", "ppn,rcr,alma_library,type,expires,comment\n123456789,,,missing,,\n"},
+		{"bad date", "ppn,rcr,alma_library,type,expires,comment\n123456789,,,,31/01/2000,\n"},
+	}
+	for _, test := range errorTests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := readExceptions(strings.NewReader(test.input)); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+}
+
+func TestApplyExceptions(t *testing.T) {
+	exceptions, err := readExceptions(strings.NewReader(exceptionsCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := Controller{
+		Config:     &Config{ExceptionsMode: ExceptionsSuppress},
+		Mappings:   &Mappings{rcr2alma: map[string][]string{"100000001": {"BIB_1"}}},
+		Exceptions: exceptions,
+	}
+	acknowledged := Summary{Type: MissingInAlma, PPN: "123456789", RCR: "100000001"}
+	expired := Summary{Type: MissingInSudoc, PPN: "123456789", RCR: "200000001", AlmaLibCode: "BIB_1"}
+
+	got := ctrl.applyExceptions("123456789", []Summary{acknowledged, expired})
+	if len(got) != 1 || got[0].Key() != expired.Key() {
+		t.Errorf("suppress: want only %v, got %v", expired, got)
+	}
+
+	ctrl.Config.ExceptionsMode = ExceptionsTag
+	got = ctrl.applyExceptions("123456789", []Summary{acknowledged})
+	if len(got) != 1 || !got[0].Acknowledged || got[0].Comment != "exemplaire de salle de lecture" {
+		t.Errorf("tag: want acknowledged anomaly, got %v", got)
+	}
+	ctrl.applyExceptions("111111111", nil)
+
+	stale := ctrl.StaleExceptions()
+	var lines []int
+	for _, s := range stale {
+		lines = append(lines, s.Line)
+	}
+	// Line 3 is expired, line 4 was checked without anomaly, line 5 matched
+	// nothing.
+	if len(lines) != 3 || lines[0] != 3 || lines[1] != 4 || lines[2] != 5 {
+		t.Errorf("want stale exceptions at lines 3, 4, 5, got %v", lines)
+	}
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"casl/entities"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var reasonLabels = map[entities.FilterReason]string{
+	entities.ReasonRCRNotFollowed:     "RCR non suivi",
+	entities.ReasonUntrackedILN:       "RCR d'un ILN non suivi, présent dans la correspondance",
+	entities.ReasonLibraryNotFollowed: "bibliothèque absente de la correspondance",
+	entities.ReasonNoDiscovery:        "masquée (suppressed from discovery)",
+	entities.ReasonIgnoredCollection:  "collection ignorée",
+	entities.ReasonNoItems:            "aucun exemplaire",
+	entities.ReasonAcquisition:        "exemplaires en commande (ACQ)",
+}
+
+// reasonsLabel returns the labels of the reasons, as a single string.
+func reasonsLabel(reasons []entities.FilterReason) string {
+	labels := make([]string, len(reasons))
+	for i, r := range reasons {
+		labels[i] = reasonLabels[r]
+		if labels[i] == "" {
+			labels[i] = string(r)
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+var filteredHeader = []string{"ppn", "source", "rcr", "epn", "alma_library_code",
+	"alma_location_code", "call_number", "reasons", "label"}
+
+// WriteFiltered writes the locations discarded from the records into a CSV
+// file in the output directory and returns its name. Reasons are separated by
+// "|".
+func (ctrl *Controller) WriteFiltered(records []entities.BibRecord) (string, error) {
+	rows := [][]string{filteredHeader}
+	for _, record := range records {
+		for _, f := range record.Filtered {
+			reasons := make([]string, len(f.Reasons))
+			for i, r := range f.Reasons {
+				reasons[i] = string(r)
+			}
+			var row []string
+			if f.Sudoc != nil {
+				row = []string{record.PPN, "sudoc", f.Sudoc.RCR, f.Sudoc.EPN, "", "", f.Sudoc.CallNumber}
+			} else {
+				row = []string{record.PPN, "alma", "", "", f.Alma.Library_code, f.Alma.Location_code, f.Alma.Call_number}
+			}
+			rows = append(rows, append(row, strings.Join(reasons, "|"), reasonsLabel(f.Reasons)))
+		}
+	}
+
+	filename := filepath.Join(ctrl.Output.Dir,
+		"filtres"+strings.TrimPrefix(resultsFilename(time.Now(), FormatCSV), "resultats"))
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", fmt.Errorf("WriteFiltered: %w", err)
+	}
+	defer f.Close()
+	if err := csv.NewWriter(f).WriteAll(rows); err != nil {
+		return "", fmt.Errorf("WriteFiltered: %w", err)
+	}
+	return filename, f.Close()
+}
+
+// filter keeps the locations of the record which must be checked. Alma
+// locations of a library mapped at location level only are discarded if their
+// own location is not mapped.
+func (ctrl *Controller) filter(record *entities.BibRecord) {
+	record.Filter(ctrl.Config.FollowedRCR, ctrl.Config.FolowedLibs, ctrl.Config.IgnoredAlmaColl)
+	if ctrl.Mappings != nil {
+		var alma []*entities.AlmaLocation
+		for _, loc := range record.AlmaLocations {
+			if len(ctrl.Mappings.rcrs(loc)) > 0 {
+				alma = append(alma, loc)
+			} else {
+				record.Filtered = append(record.Filtered, entities.FilteredLocation{
+					Alma: loc, Reasons: []entities.FilterReason{entities.ReasonLibraryNotFollowed}})
+			}
+		}
+		record.AlmaLocations = alma
+	}
+	ctrl.flagUntracked(record)
+}
+
+// flagUntracked adds ReasonUntrackedILN to the filtered SUDOC locations whose
+// RCR is mapped to an Alma library but belongs to an ILN which is not tracked:
+// they are not checked although the mappings expect them.
+func (ctrl *Controller) flagUntracked(record *entities.BibRecord) {
+	if ctrl.Mappings == nil {
+		return
+	}
+	for i, f := range record.Filtered {
+		if f.Sudoc == nil || len(ctrl.Mappings.rcr2alma[f.Sudoc.RCR]) == 0 ||
+			slices.Contains(ctrl.Config.ILNs, f.Sudoc.ILN) {
+			continue
+		}
+		record.Filtered[i].Reasons = append(f.Reasons, entities.ReasonUntrackedILN)
+	}
+}
+
+// Untracked returns the SUDOC locations of the record flagged by flagUntracked.
+func Untracked(record entities.BibRecord) []*entities.SudocLocation {
+	var locs []*entities.SudocLocation
+	for _, f := range record.Filtered {
+		if f.Sudoc != nil && slices.Contains(f.Reasons, entities.ReasonUntrackedILN) {
+			locs = append(locs, f.Sudoc)
+		}
+	}
+	return locs
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"casl/entities"
+	"encoding/csv"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestWriteFiltered(t *testing.T) {
+	ctrl := Controller{Output: OutputOptions{Dir: t.TempDir()}}
+	records := []entities.BibRecord{{
+		PPN: "123456789",
+		Filtered: []entities.FilteredLocation{
+			{Sudoc: &entities.SudocLocation{RCR: "900000001", EPN: "EP1"},
+				Reasons: []entities.FilterReason{entities.ReasonRCRNotFollowed}},
+			{Alma: &entities.AlmaLocation{Library_code: "BIB_1", Location_code: "MAG"},
+				Reasons: []entities.FilterReason{entities.ReasonNoDiscovery, entities.ReasonNoItems}},
+		},
+	}}
+	filename, err := ctrl.WriteFiltered(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("want 3 rows, got %d", len(rows))
+	}
+	if rows[1][1] != "sudoc" || rows[1][2] != "900000001" || rows[1][7] != "rcr_not_followed" {
+		t.Errorf("unexpected SUDOC row %v", rows[1])
+	}
+	if rows[2][1] != "alma" || rows[2][4] != "BIB_1" || rows[2][7] != "no_discovery|no_items" ||
+		rows[2][8] != "masquée (suppressed from discovery), aucun exemplaire" {
+		t.Errorf("unexpected Alma row %v", rows[2])
+	}
+}
+
+func TestFlagUntracked(t *testing.T) {
+	ctrl := Controller{
+		Config:   &Config{ILNs: []string{"1"}, FollowedRCR: []string{"100000001"}},
+		Mappings: &Mappings{rcr2alma: map[string][]string{"100000001": {"BIB_1"}, "300000001": {"BIB_3"}}},
+	}
+	record := entities.BibRecord{SudocLocations: []*entities.SudocLocation{
+		{ILN: "1", RCR: "100000001"},
+		{ILN: "3", RCR: "300000001"},
+		{ILN: "9", RCR: "900000001"},
+	}}
+	record.Filter(ctrl.Config.FollowedRCR, nil, nil)
+	ctrl.flagUntracked(&record)
+
+	untracked := Untracked(record)
+	if len(untracked) != 1 || untracked[0].RCR != "300000001" {
+		t.Fatalf("want RCR 300000001, got %v", untracked)
+	}
+	want := []entities.FilterReason{entities.ReasonRCRNotFollowed, entities.ReasonUntrackedILN}
+	if got := record.Reasons(untracked[0]); !slices.Equal(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"time"
+)
+
+//go:embed templates/report.html
+var htmlTemplate string
+
+var reportTemplate = template.Must(template.New("report").Parse(htmlTemplate))
+
+// htmlReport is the data given to the HTML template.
+type htmlReport struct {
+	GeneratedAt    time.Time
+	MissingInAlma  AnomalyType
+	MissingInSudoc AnomalyType
+	CountInAlma    int
+	CountInSudoc   int
+	ByRCR          []htmlStat
+	ByAlma         []htmlStat
+	Anomalies      []htmlAnomaly
+}
+
+// htmlStat counts the anomalies of a library.
+type htmlStat struct {
+	Key     string
+	Label   string
+	InAlma  int
+	InSudoc int
+	Total   int
+}
+
+type htmlAnomaly struct {
+	Summary
+	SudocURL        string
+	AlmaURL         string
+	Acknowledgement string
+}
+
+func (s *htmlStat) add(t AnomalyType) {
+	switch t {
+	case MissingInAlma:
+		s.InAlma++
+	case MissingInSudoc:
+		s.InSudoc++
+	}
+	s.Total++
+}
+
+// encodeHTML writes a standalone HTML page, without any external resource.
+func (ctrl *Controller) encodeHTML(w io.Writer, results []Summary) error {
+	report := htmlReport{
+		GeneratedAt:    time.Now(),
+		MissingInAlma:  MissingInAlma,
+		MissingInSudoc: MissingInSudoc,
+	}
+
+	report.ByRCR = ctrl.stats(results, GroupByRCR)
+	report.ByAlma = ctrl.stats(results, GroupByAlma)
+
+	for _, res := range results {
+		switch res.Type {
+		case MissingInAlma:
+			report.CountInAlma++
+		case MissingInSudoc:
+			report.CountInSudoc++
+		}
+		report.Anomalies = append(report.Anomalies, htmlAnomaly{
+			Summary:         res,
+			SudocURL:        sudocURL + res.PPN,
+			AlmaURL:         ctrl.almaURL(res.MMS),
+			Acknowledgement: acknowledgement(res),
+		})
+	}
+
+	return reportTemplate.Execute(w, report)
+}
+
+// stats counts the anomalies by RCR or by Alma library.
+func (ctrl *Controller) stats(results []Summary, by string) []htmlStat {
+	var stats []htmlStat
+	for _, g := range ctrl.groupResults(results, by) {
+		stat := htmlStat{Key: g.Key, Label: g.Label}
+		for _, res := range g.Results {
+			stat.add(res.Type)
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
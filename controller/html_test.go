@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeHTML(t *testing.T) {
+	ctrl := Controller{
+		Config: &Config{AlmaBibURL: "https://alma.example.org/bib/{mms}"},
+		Mappings: &Mappings{
+			rcr2alma: map[string][]string{"100000001": {"BIB_1", "BIB_2"}},
+			alma2str: map[string]string{"BIB_1": "Bibliothèque 1", "BIB_2": "Bibliothèque 2"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := ctrl.encodeHTML(&buf, provideSummaries()); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, want := range []string{
+		`<a href="https://www.sudoc.fr/123456789">123456789</a>`,
+		`<a href="https://alma.example.org/bib/mms_1">mms_1</a>`,
+		`data-type="missing_in_sudoc" data-rcr="200000001"`,
+		`Bibliothèque 2`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	for _, external := range []string{"<link", "src="} {
+		if strings.Contains(page, external) {
+			t.Errorf("page should not load external resources (%s)", external)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	ctrl := Controller{Mappings: &Mappings{
+		rcr2alma: map[string][]string{"100000001": {"BIB_1", "BIB_2"}},
+		alma2str: map[string]string{"BIB_1": "Bibliothèque 1", "BIB_2": "Bibliothèque 2"},
+	}}
+	want := []htmlStat{
+		{Key: "BIB_1", Label: "Bibliothèque 1", InAlma: 1, InSudoc: 1, Total: 2},
+		{Key: "BIB_2", Label: "Bibliothèque 2", InAlma: 1, Total: 1},
+	}
+	got := ctrl.stats(provideSummaries(), GroupByAlma)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
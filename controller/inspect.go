@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"casl/entities"
+	"casl/exl"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Inspection is the detail of the check of a single PPN: every location found
+// on each side, why it was filtered out, and how it is classified by Compare.
+type Inspection struct {
+	// Skipped tells why the record is not checked, if it is not.
+	Skipped string
+	// NotInAlma is set if the PPN is not found in Alma: check then fails to
+	// fetch it and does not compare its locations.
+	NotInAlma bool
+	Record    entities.BibRecord
+	Sudoc     []InspectedSudoc
+	Alma      []InspectedAlma
+	Anomalies []Summary
+}
+
+// InspectedSudoc is a SUDOC location of an inspected PPN. Reasons is empty if
+// the location is checked. Matches are the codes of the Alma libraries
+// holding a matching location.
+type InspectedSudoc struct {
+	*entities.SudocLocation
+	Reasons []entities.FilterReason
+	Matches []string
+}
+
+// InspectedAlma is an Alma location of an inspected PPN. Reasons is empty if
+// the location is checked. Matches are the RCRs of the SUDOC locations
+// matching it.
+type InspectedAlma struct {
+	*entities.AlmaLocation
+	Reasons []entities.FilterReason
+	Matches []string
+}
+
+// Status tells how a location is classified: filtered out, matched, or
+// missing on the other side.
+func (s InspectedSudoc) Status() string {
+	return status(s.Reasons, s.Matches, MissingInAlma)
+}
+
+// Status tells how a location is classified: filtered out, matched, or
+// missing on the other side.
+func (a InspectedAlma) Status() string {
+	return status(a.Reasons, a.Matches, MissingInSudoc)
+}
+
+func status(reasons []entities.FilterReason, matches []string, missing AnomalyType) string {
+	switch {
+	case len(reasons) > 0:
+		return "filtré : " + reasonsLabel(reasons)
+	case len(matches) > 0:
+		return fmt.Sprintf("OK %v", matches)
+	default:
+		return missing.Label()
+	}
+}
+
+// Inspect fetches all the locations of a PPN, on both sides, and explains the
+// result of its check.
+func (ctrl *Controller) Inspect(ctx context.Context, ppn string) (*Inspection, error) {
+	sudocRecord, err := ctrl.SUClient.GetRecordContext(ctx, ppn)
+	if err != nil {
+		return nil, err
+	}
+	allSudoc, err := ctrl.SUClient.RecordLocationsContext(ctx, ppn, sudocRecord)
+	if err != nil {
+		return nil, err
+	}
+	allAlma, err := ctrl.AlmaClient.GetLocationsContext(ctx, ppn)
+	var notFound *exl.NotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		return nil, err
+	}
+	insp := Inspection{Skipped: ctrl.skipReason(sudocRecord), NotInAlma: notFound != nil, Record: entities.BibRecord{
+		PPN:            ppn,
+		Sudoc:          sudocRecord,
+		SudocLocations: allSudoc,
+		AlmaLocations:  allAlma,
+		FetchedAt:      time.Now(),
+	}}
+	if len(allAlma) > 0 {
+		insp.Record.MMS = allAlma[0].MMS
+	}
+	ctrl.filter(&insp.Record)
+	sudoc, alma := insp.Record.SudocLocations, insp.Record.AlmaLocations
+
+	for _, loc := range allSudoc {
+		s := InspectedSudoc{SudocLocation: loc, Reasons: insp.Record.Reasons(loc)}
+		if len(s.Reasons) == 0 {
+			for _, aloc := range alma {
+				if slices.Contains(ctrl.Mappings.rcrs(aloc), loc.RCR) && !slices.Contains(s.Matches, aloc.Library_code) {
+					s.Matches = append(s.Matches, aloc.Library_code)
+				}
+			}
+		}
+		insp.Sudoc = append(insp.Sudoc, s)
+	}
+
+	for _, loc := range allAlma {
+		a := InspectedAlma{AlmaLocation: loc, Reasons: insp.Record.Reasons(loc)}
+		if len(a.Reasons) == 0 {
+			rcrs := ctrl.Mappings.rcrs(loc)
+			for _, sloc := range sudoc {
+				if slices.Contains(rcrs, sloc.RCR) && !slices.Contains(a.Matches, sloc.RCR) {
+					a.Matches = append(a.Matches, sloc.RCR)
+				}
+			}
+		}
+		insp.Alma = append(insp.Alma, a)
+	}
+
+	if !insp.NotInAlma {
+		insp.Anomalies = ctrl.Compare(&insp.Record)
+	}
+	return &insp, nil
+}
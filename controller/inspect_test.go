@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"casl/entities"
+	"casl/exl"
+	"casl/marc"
+	"casl/sudoc"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// fakeSudoc and fakeAlma serve fixed locations, filtered like the real
+// clients.
+type fakeSudoc struct {
+	record    *marc.Record
+	locations []*entities.SudocLocation
+	// libraries are the ILN and name of the followed RCRs.
+	libraries map[string][2]string
+	// unreachable are the RCRs whose lookup fails.
+	unreachable []string
+}
+
+func (f fakeSudoc) GetRecord(ppn string) (*marc.Record, error) {
+	if f.record != nil {
+		return f.record, nil
+	}
+	return &marc.Record{Leader: "     nam0 22        450 "}, nil
+}
+
+func (f fakeSudoc) GetRecordContext(ctx context.Context, ppn string) (*marc.Record, error) {
+	return f.GetRecord(ppn)
+}
+
+func (f fakeSudoc) GetLocationsContext(ctx context.Context, ppn string) ([]*entities.SudocLocation, error) {
+	return f.GetLocations(ppn)
+}
+
+func (f fakeSudoc) GetLocations(ppn string) ([]*entities.SudocLocation, error) {
+	var locs []*entities.SudocLocation
+	for _, l := range f.locations {
+		loc := *l
+		locs = append(locs, &loc)
+	}
+	return locs, nil
+}
+
+func (f fakeSudoc) RecordLocations(ppn string, record *marc.Record) ([]*entities.SudocLocation, error) {
+	return f.GetLocations(ppn)
+}
+
+func (f fakeSudoc) RecordLocationsContext(ctx context.Context, ppn string, record *marc.Record) ([]*entities.SudocLocation, error) {
+	return f.GetLocations(ppn)
+}
+
+func (f fakeSudoc) GetFilteredLocations(ppn string, rcrs []string) ([]*entities.SudocLocation, error) {
+	var filtered []*entities.SudocLocation
+	locs, _ := f.GetLocations(ppn)
+	for _, l := range locs {
+		if slices.Contains(rcrs, l.RCR) {
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered, nil
+}
+
+func (f fakeSudoc) Stats(t string) int { return 0 }
+func (f fakeSudoc) GetFollowedRCRs() []string {
+	var rcrs []string
+	for rcr := range f.libraries {
+		rcrs = append(rcrs, rcr)
+	}
+	return rcrs
+}
+
+func (f fakeSudoc) GetLibraryContext(ctx context.Context, rcr string) (string, string, error) {
+	return f.GetLibrary(rcr)
+}
+
+func (f fakeSudoc) GetLibrary(rcr string) (string, string, error) {
+	if slices.Contains(f.unreachable, rcr) {
+		return "", "", errors.New("timeout")
+	}
+	lib, ok := f.libraries[rcr]
+	if !ok {
+		return "", "", sudoc.ErrUnknownRCR
+	}
+	return lib[0], lib[1], nil
+}
+
+type fakeAlma struct {
+	locations []*entities.AlmaLocation
+	bib       *marc.Record
+	libraries []exl.Library
+	// err is returned by GetLocations.
+	err error
+}
+
+func (f fakeAlma) GetLibraries() ([]exl.Library, error) { return f.libraries, nil }
+
+func (f fakeAlma) GetLibrariesContext(ctx context.Context) ([]exl.Library, error) {
+	return f.GetLibraries()
+}
+
+func (f fakeAlma) GetBibContext(ctx context.Context, mms string) (*marc.Record, error) {
+	return f.GetBib(mms)
+}
+
+func (f fakeAlma) GetBib(mms string) (*marc.Record, error) {
+	if f.bib == nil {
+		return nil, errors.New("no bib")
+	}
+	return f.bib, nil
+}
+
+func (f fakeAlma) GetLocations(ppn string) ([]*entities.AlmaLocation, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var locs []*entities.AlmaLocation
+	for _, l := range f.locations {
+		loc := *l
+		locs = append(locs, &loc)
+	}
+	return locs, nil
+}
+
+func (f fakeAlma) GetLocationsContext(ctx context.Context, ppn string) ([]*entities.AlmaLocation, error) {
+	return f.GetLocations(ppn)
+}
+
+func (f fakeAlma) GetFilteredLocations(ppn string, libs []string, ignored []string) ([]*entities.AlmaLocation, error) {
+	var filtered []*entities.AlmaLocation
+	locs, _ := f.GetLocations(ppn)
+	for _, l := range locs {
+		if ok, _ := l.IsValid(ignored); ok && slices.Contains(libs, l.Library_code) {
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered, nil
+}
+
+func (f fakeAlma) Stats(t string) int { return 0 }
+
+func TestInspect(t *testing.T) {
+	items := []*entities.AlmaItem{{Process_code: ""}}
+	ctrl := Controller{
+		Config: &Config{
+			FollowedRCR:     []string{"100000001", "200000001"},
+			FolowedLibs:     []string{"BIB_1", "BIB_2", "BIB_3"},
+			IgnoredAlmaColl: []string{"MAG"},
+			ExceptionsMode:  ExceptionsSuppress,
+		},
+		Mappings: &Mappings{
+			alma2rcr: map[string][]string{"BIB_1": {"100000001"}, "BIB_2": {"200000001"}, "BIB_3": {"300000001"}},
+			rcr2alma: map[string][]string{"100000001": {"BIB_1"}, "200000001": {"BIB_2"}, "300000001": {"BIB_3"}},
+		},
+		SUClient: fakeSudoc{locations: []*entities.SudocLocation{
+			{RCR: "100000001", EPN: "EP1"},
+			{RCR: "200000001", EPN: "EP2"},
+			{RCR: "900000001", EPN: "EP9"},
+		}},
+		AlmaClient: fakeAlma{locations: []*entities.AlmaLocation{
+			{MMS: "mms_1", Library_code: "BIB_1", Location_code: "LIB", Items: items},
+			{MMS: "mms_1", Library_code: "BIB_2", Location_code: "MAG", Items: items},
+			{MMS: "mms_1", Library_code: "BIB_3", Location_code: "LIB", Items: items},
+			{MMS: "mms_1", Library_code: "BIB_4", Location_code: "LIB", Items: items},
+		}},
+	}
+
+	insp, err := ctrl.Inspect(context.Background(), "123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if insp.Record.MMS != "mms_1" || len(insp.Sudoc) != 3 || len(insp.Alma) != 4 {
+		t.Fatalf("unexpected inspection %+v", insp)
+	}
+	wantSudoc := []string{"OK [BIB_1]", "Absent d'Alma", "filtré : RCR non suivi"}
+	for i, s := range insp.Sudoc {
+		if got := s.Status(); got != wantSudoc[i] {
+			t.Errorf("SUDOC %s: want %q, got %q", s.RCR, wantSudoc[i], got)
+		}
+	}
+	wantAlma := []string{"OK [100000001]", "filtré : collection ignorée", "Absent du SUDOC",
+		"filtré : bibliothèque absente de la correspondance"}
+	for i, a := range insp.Alma {
+		if got := a.Status(); got != wantAlma[i] {
+			t.Errorf("Alma %s: want %q, got %q", a.Library_code, wantAlma[i], got)
+		}
+	}
+	if len(insp.Anomalies) != 2 {
+		t.Errorf("want 2 anomalies, got %v", insp.Anomalies)
+	}
+}
+
+func TestInspectNotInAlma(t *testing.T) {
+	ctrl := Controller{
+		Config:   &Config{FollowedRCR: []string{"100000001"}, ExceptionsMode: ExceptionsSuppress},
+		Mappings: &Mappings{alma2rcr: map[string][]string{"BIB_1": {"100000001"}}},
+		SUClient: fakeSudoc{locations: []*entities.SudocLocation{
+			{RCR: "100000001", EPN: "EP1"},
+			{RCR: "900000001", EPN: "EP9"},
+		}},
+		AlmaClient: fakeAlma{err: &exl.NotFoundError{}},
+	}
+	insp, err := ctrl.Inspect(context.Background(), "123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !insp.NotInAlma || len(insp.Sudoc) != 2 || len(insp.Alma) != 0 || len(insp.Anomalies) != 0 {
+		t.Errorf("want every SUDOC location and no anomaly, got %+v", insp)
+	}
+
+	ctrl.AlmaClient = fakeAlma{err: errors.New("timeout")}
+	if _, err := ctrl.Inspect(context.Background(), "123456789"); err == nil {
+		t.Error("want the error of Alma")
+	}
+}
+
+func TestFetchSkipped(t *testing.T) {
+	unimarc := func(leader string) *marc.Record {
+		return &marc.Record{Leader: leader, Datafields: []marc.Datafield{{Tag: "200"}}}
+	}
+	tests := []struct {
+		name       string
+		record     *marc.Record
+		levels     []string
+		electronic bool
+		skipped    bool
+	}{
+		{"monograph", unimarc("     nam0 22        450 "), []string{"m"}, true, false},
+		{"deleted", unimarc("     dam0 22        450 "), nil, false, true},
+		{"serial", unimarc("     nas0 22        450 "), []string{"m"}, false, true},
+		{"any level", unimarc("     nas0 22        450 "), nil, false, false},
+		{"electronic", unimarc("     nlm0 22        450 "), nil, true, true},
+		{"electronic kept", unimarc("     nlm0 22        450 "), nil, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := Controller{
+				Config:     &Config{BibLevels: test.levels, SkipElectronic: test.electronic},
+				SUClient:   fakeSudoc{record: test.record},
+				AlmaClient: fakeAlma{},
+			}
+			_, err := ctrl.Fetch("123456789")
+			if skipped := errors.Is(err, ErrSkippedRecord); skipped != test.skipped {
+				t.Errorf("want skipped %t, got %v", test.skipped, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package controller
+
+import (
+	"casl/entities"
+	"casl/sudoc"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MappingHeader are the columns of the alma-rcr mapping file in format 2, where
+// they are given by the first line in any order. alma_library_code, rcr and
+// iln are required.
+var MappingHeader = []string{"alma_library", "alma_library_code", "alma_location_code", "rcr", "iln",
+	"sudoc_label", "valid_from", "valid_to", "comment"}
+
+// ActiveAt reports whether the mapping applies at the given time: validity
+// dates are included, and empty ones are unbounded.
+func (row MappingRow) ActiveAt(t time.Time) bool {
+	return (row.ValidFrom.IsZero() || !t.Before(row.ValidFrom)) &&
+		(row.ValidTo.IsZero() || t.Before(row.ValidTo.AddDate(0, 0, 1)))
+}
+
+// overlaps reports whether the validity periods of two mappings overlap.
+func (row MappingRow) overlaps(other MappingRow) bool {
+	before := func(end, start time.Time) bool { return !end.IsZero() && !start.IsZero() && end.Before(start) }
+	return !before(row.ValidTo, other.ValidFrom) && !before(other.ValidTo, row.ValidFrom)
+}
+
+// getMappingsFromCSV reads the alma-rcr mapping file.
+func (ctrl *Controller) getMappingsFromCSV(csv_file string) error {
+	f, err := os.Open(csv_file)
+	if err != nil {
+		return fmt.Errorf("getMappingsFromCSV: %w", err)
+	}
+	defer f.Close()
+	maps, err := readMappings(f, time.Now())
+	if err != nil {
+		return fmt.Errorf("getMappingsFromCSV: %s: %w", csv_file, err)
+	}
+	ctrl.Mappings = maps
+	return nil
+}
+
+// readMappings reads an alma-rcr mapping. Format 1 has no header and four
+// columns:
+// "Library name","Library code",RCR,ILN
+// Format 2 starts with a header of MappingHeader columns. In both formats,
+// lines starting with # are comments. Rows which do not apply at the given time
+// are kept in rows, but not mapped.
+func readMappings(r io.Reader, now time.Time) (*Mappings, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	maps := &Mappings{
+		version:  1,
+		alma2rcr: make(map[string][]string),
+		loc2rcr:  make(map[string][]string),
+		rcr2alma: make(map[string][]string),
+		rcr2iln:  make(map[string]string),
+		alma2str: make(map[string]string),
+		rcr2str:  make(map[string]string),
+	}
+	var columns map[string]int
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if first {
+			if columns, err = mappingColumns(record, line); err != nil {
+				return nil, err
+			}
+			if columns != nil {
+				maps.version = 2
+				continue
+			}
+		}
+		row, err := parseMappingRow(record, columns, line)
+		if err != nil {
+			return nil, err
+		}
+		maps.add(row, now)
+	}
+	return maps, nil
+}
+
+// mappingColumns returns the positions of the columns given by a format 2
+// header, or nil if the line is not a header.
+func mappingColumns(header []string, line int) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["alma_library_code"]; !ok {
+		return nil, nil
+	}
+	for _, name := range []string{"rcr", "iln"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("line %d: missing column %q", line, name)
+		}
+	}
+	return columns, nil
+}
+
+// parseMappingRow reads a line of the mapping file, in format 1 if there are no
+// columns.
+func parseMappingRow(record []string, columns map[string]int, line int) (MappingRow, error) {
+	if columns == nil {
+		if len(record) < 4 {
+			return MappingRow{}, fmt.Errorf("line %d: want 4 columns, got %d", line, len(record))
+		}
+		return MappingRow{AlmaName: record[0], AlmaCode: record[1], RCR: record[2], ILN: record[3], Line: line}, nil
+	}
+
+	get := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	row := MappingRow{
+		AlmaName:     get("alma_library"),
+		AlmaCode:     get("alma_library_code"),
+		AlmaLocation: get("alma_location_code"),
+		RCR:          get("rcr"),
+		ILN:          get("iln"),
+		SudocName:    get("sudoc_label"),
+		Comment:      get("comment"),
+		Line:         line,
+	}
+	for _, date := range []struct {
+		column string
+		value  *time.Time
+	}{{"valid_from", &row.ValidFrom}, {"valid_to", &row.ValidTo}} {
+		if v := get(date.column); v != "" {
+			t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+			if err != nil {
+				return row, fmt.Errorf("line %d: invalid %s date %q, want YYYY-MM-DD", line, date.column, v)
+			}
+			*date.value = t
+		}
+	}
+	if !row.ValidFrom.IsZero() && !row.ValidTo.IsZero() && row.ValidTo.Before(row.ValidFrom) {
+		return row, fmt.Errorf("line %d: valid_to is before valid_from", line)
+	}
+	return row, nil
+}
+
+// add adds a row to the mapping, and maps it if it applies at the given time.
+// A location-level row maps the RCR to a single location of the library.
+func (m *Mappings) add(row MappingRow, now time.Time) {
+	m.rows = append(m.rows, row)
+	if !row.ActiveAt(now) {
+		return
+	}
+	if row.AlmaLocation != "" {
+		key := row.AlmaCode + "/" + row.AlmaLocation
+		m.loc2rcr[key] = append(m.loc2rcr[key], row.RCR)
+	} else {
+		m.alma2rcr[row.AlmaCode] = append(m.alma2rcr[row.AlmaCode], row.RCR)
+	}
+	if !slices.Contains(m.rcr2alma[row.RCR], row.AlmaCode) {
+		m.rcr2alma[row.RCR] = append(m.rcr2alma[row.RCR], row.AlmaCode)
+	}
+	m.rcr2iln[row.RCR] = row.ILN
+	if _, ok := m.alma2str[row.AlmaCode]; !ok || row.AlmaName != "" {
+		m.alma2str[row.AlmaCode] = row.AlmaName
+	}
+	if row.SudocName != "" {
+		m.rcr2str[row.RCR] = row.SudocName
+	}
+}
+
+// rcrs returns the RCRs mapped to an Alma location: those of the location if
+// it has location-level mappings, else those of its library.
+func (m *Mappings) rcrs(loc *entities.AlmaLocation) []string {
+	if rcrs, ok := m.loc2rcr[loc.Library_code+"/"+loc.Location_code]; ok {
+		return rcrs
+	}
+	return m.alma2rcr[loc.Library_code]
+}
+
+// MappingCheck is the result of CheckMappings.
+type MappingCheck struct {
+	// Problems are the errors of the mapping.
+	Problems []error
+	// Failures are the RCRs which could not be looked up in the SUDOC: they
+	// are not checked.
+	Failures []error
+}
+
+// CheckMappings checks the alma-rcr mapping against the Alma libraries and the
+// SUDOC libraries: unknown Alma codes, unknown RCRs, RCRs of another ILN than
+// the mapped one, and Alma libraries without any RCR. It returns an error if
+// the Alma libraries cannot be read.
+func (ctrl *Controller) CheckMappings(ctx context.Context) (*MappingCheck, error) {
+	libraries, err := ctrl.AlmaClient.GetLibrariesContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("CheckMappings: %w", err)
+	}
+	codes := make(map[string]bool)
+	for _, lib := range libraries {
+		codes[lib.Code] = true
+	}
+
+	var check MappingCheck
+	for _, row := range ctrl.MappingRows() {
+		if row.AlmaCode != "" && !codes[row.AlmaCode] {
+			check.Problems = append(check.Problems, fmt.Errorf("mapping line %d: unknown Alma library %q", row.Line, row.AlmaCode))
+		}
+		if !rcrPattern.MatchString(row.RCR) {
+			continue
+		}
+		iln, _, err := ctrl.SUClient.GetLibraryContext(ctx, row.RCR)
+		switch {
+		case errors.Is(err, sudoc.ErrUnknownRCR):
+			check.Problems = append(check.Problems, fmt.Errorf("mapping line %d: unknown RCR %q", row.Line, row.RCR))
+		case err != nil:
+			check.Failures = append(check.Failures, fmt.Errorf("mapping line %d: RCR %s not checked: %w", row.Line, row.RCR, err))
+		case iln != row.ILN:
+			check.Problems = append(check.Problems, fmt.Errorf("mapping line %d: RCR %s belongs to ILN %s, not %s",
+				row.Line, row.RCR, iln, row.ILN))
+		}
+	}
+	for _, lib := range libraries {
+		if _, ok := ctrl.Mappings.alma2str[lib.Code]; !ok {
+			check.Problems = append(check.Problems, fmt.Errorf("Alma library %s (%s) has no RCR", lib.Code, lib.Name))
+		}
+	}
+	return &check, nil
+}
+
+// ProposeMappings proposes a mapping of the Alma libraries to the RCRs of the
+// tracked ILNs, by matching the words of the Alma names and codes with those of
+// the SUDOC short names: the RCR sharing the most distinctive words wins. Rows
+// of the Alma libraries which match no RCR have an empty RCR and ILN; those
+// which match several RCRs equally have a row for each one.
+func (ctrl *Controller) ProposeMappings(ctx context.Context) ([]MappingRow, error) {
+	libraries, err := ctrl.AlmaClient.GetLibrariesContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ProposeMappings: %w", err)
+	}
+	type sudocLib struct {
+		rcr, iln, name string
+		words          []string
+	}
+	var sudocLibs []sudocLib
+	// Words common to many SUDOC names ("bibliothèque", the name of the
+	// university...) weigh less than distinctive ones.
+	frequency := make(map[string]int)
+	rcrs := ctrl.SUClient.GetFollowedRCRs()
+	sort.Strings(rcrs)
+	for _, rcr := range rcrs {
+		iln, name, err := ctrl.SUClient.GetLibraryContext(ctx, rcr)
+		if err != nil {
+			return nil, fmt.Errorf("ProposeMappings: %w", err)
+		}
+		s := sudocLib{rcr, iln, name, nameWords(name)}
+		for _, w := range s.words {
+			frequency[w]++
+		}
+		sudocLibs = append(sudocLibs, s)
+	}
+
+	var rows []MappingRow
+	for _, lib := range libraries {
+		words := nameWords(lib.Name + " " + lib.Code)
+		best, matches := 0.0, []sudocLib(nil)
+		for _, s := range sudocLibs {
+			score := 0.0
+			for _, w := range s.words {
+				if slices.Contains(words, w) {
+					score += 1 / float64(frequency[w])
+				}
+			}
+			switch {
+			case score > best:
+				best, matches = score, []sudocLib{s}
+			case score == best && score > 0:
+				matches = append(matches, s)
+			}
+		}
+		if len(matches) == 0 {
+			rows = append(rows, MappingRow{AlmaName: lib.Name, AlmaCode: lib.Code})
+		}
+		for _, s := range matches {
+			rows = append(rows, MappingRow{AlmaName: lib.Name, AlmaCode: lib.Code, RCR: s.rcr, ILN: s.iln, SudocName: s.name})
+		}
+	}
+	return rows, nil
+}
+
+// nameWords splits a library name into lowercase words of at least 3
+// characters, or digits.
+func nameWords(name string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(name), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	}) {
+		if (len([]rune(w)) >= 3 || strings.IndexFunc(w, unicode.IsLetter) < 0) && !slices.Contains(words, w) {
+			words = append(words, w)
+		}
+	}
+	return words
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"casl/entities"
+	"casl/exl"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMappingsTools(t *testing.T) {
+	ctrl := Controller{
+		Config: &Config{ILNs: []string{"1"}},
+		Mappings: &Mappings{
+			rows: []MappingRow{
+				{AlmaCode: "BU_SCI", RCR: "100000001", ILN: "1", Line: 1},
+				{AlmaCode: "BU_DROIT", RCR: "100000002", ILN: "2", Line: 2},
+				{AlmaCode: "BU_OLD", RCR: "100000009", ILN: "1", Line: 3},
+				{AlmaCode: "BU_SCI", RCR: "100000008", ILN: "1", Line: 4},
+			},
+			alma2rcr: map[string][]string{"BU_SCI": {"100000001"}, "BU_DROIT": {"100000002"}, "BU_OLD": {"100000009"}},
+			alma2str: map[string]string{"BU_SCI": "", "BU_DROIT": "", "BU_OLD": ""},
+		},
+		SUClient: fakeSudoc{libraries: map[string][2]string{
+			"100000001": {"1", "UNIV-BU Sciences"},
+			"100000002": {"1", "UNIV-BU Droit"},
+			"100000003": {"1", "UNIV-Bibliothèque de médecine"},
+		}, unreachable: []string{"100000008"}},
+		AlmaClient: fakeAlma{libraries: []exl.Library{
+			{Name: "BU Sciences et techniques", Code: "BU_SCI"},
+			{Name: "BU Droit-Économie", Code: "BU_DROIT"},
+			{Name: "Bibliothèque Médecine", Code: "BU_MED"},
+			{Name: "Réserve", Code: "RES"},
+		}},
+	}
+
+	check, err := ctrl.CheckMappings(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range check.Problems {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		"mapping line 2: RCR 100000002 belongs to ILN 1, not 2",
+		`mapping line 3: unknown Alma library "BU_OLD"`,
+		`mapping line 3: unknown RCR "100000009"`,
+		"Alma library BU_MED (Bibliothèque Médecine) has no RCR",
+		"Alma library RES (Réserve) has no RCR",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("want\n%q\ngot\n%q", want, got)
+	}
+	// A lookup failure is not a problem of the mapping.
+	if len(check.Failures) != 1 || !strings.Contains(check.Failures[0].Error(), "RCR 100000008 not checked: timeout") {
+		t.Errorf("want the lookup failure of 100000008, got %v", check.Failures)
+	}
+
+	rows, err := ctrl.ProposeMappings(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, row := range rows {
+		got = append(got, fmt.Sprintf("%s:%s:%s", row.AlmaCode, row.RCR, row.ILN))
+	}
+	want = []string{"BU_SCI:100000001:1", "BU_DROIT:100000002:1", "BU_MED:100000003:1", "RES::"}
+	if !slices.Equal(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestReadMappings(t *testing.T) {
+	data := "\ufeffalma_library_code,alma_location_code,rcr,iln,sudoc_label,valid_from,valid_to,comment\n" +
+		"# BU_OLD merged into BU_SCI\n" +
+		"BU_OLD,,100000009,1,,,2023-12-31,fusion\n" +
+		"BU_SCI,,100000001,1,UNIV-BU Sciences,2024-01-01,,\n" +
+		"BU_SCI,RES,100000002,1,UNIV-Réserve,,,\n"
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+	maps, err := readMappings(strings.NewReader(data), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maps.version != 2 || len(maps.rows) != 3 || maps.rows[0].Line != 3 || maps.rows[0].Comment != "fusion" {
+		t.Fatalf("unexpected rows %+v", maps.rows)
+	}
+	if _, ok := maps.alma2str["BU_OLD"]; ok {
+		t.Error("want expired mapping not followed")
+	}
+	if maps.rcr2str["100000002"] != "UNIV-Réserve" {
+		t.Errorf("want SUDOC label, got %q", maps.rcr2str["100000002"])
+	}
+	shelves := entities.AlmaLocation{Library_code: "BU_SCI", Location_code: "LIB"}
+	reserve := entities.AlmaLocation{Library_code: "BU_SCI", Location_code: "RES"}
+	if got := maps.rcrs(&shelves); !slices.Equal(got, []string{"100000001"}) {
+		t.Errorf("library mapping: want 100000001, got %v", got)
+	}
+	if got := maps.rcrs(&reserve); !slices.Equal(got, []string{"100000002"}) {
+		t.Errorf("location mapping: want 100000002, got %v", got)
+	}
+	if got := maps.rcr2alma["100000002"]; !slices.Equal(got, []string{"BU_SCI"}) {
+		t.Errorf("want RCR mapped to BU_SCI, got %v", got)
+	}
+
+	// The format 1 is still read.
+	maps, err = readMappings(strings.NewReader(`"BU Sciences",BU_SCI,100000001,1`+"\n"), now)
+	if err != nil || maps.version != 1 || maps.alma2rcr["BU_SCI"][0] != "100000001" {
+		t.Errorf("format 1: unexpected mapping %+v, %v", maps, err)
+	}
+
+	for _, bad := range []string{
+		"alma_library_code,rcr\nBU_SCI,100000001\n",
+		"alma_library_code,rcr,iln,valid_from\nBU_SCI,100000001,1,01/01/2024\n",
+		"alma_library_code,rcr,iln,valid_from,valid_to\nBU_SCI,100000001,1,2024-01-01,2023-01-01\n",
+	} {
+		if _, err := readMappings(strings.NewReader(bad), now); err == nil {
+			t.Errorf("want an error for %q", bad)
+		}
+	}
+}
+
+func TestValidateMappingsPeriods(t *testing.T) {
+	data := "alma_library_code,rcr,iln,valid_from,valid_to\n" +
+		"BU_SCI,100000001,1,,2023-12-31\n" +
+		"BU_SCI,100000001,1,2024-01-01,\n" +
+		"BU_SCI,100000001,1,2024-06-01,\n"
+	maps, err := readMappings(strings.NewReader(data), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := Controller{Config: &Config{ILNs: []string{"1"}}, Mappings: maps}
+	errs := ctrl.ValidateMappings()
+	if len(errs) != 1 || errs[0].Error() != "mapping line 4: BU_SCI/100000001 already mapped line 3" {
+		t.Errorf("want one overlapping mapping, got %v", errs)
+	}
+}
+
+func TestCompareLocationMapping(t *testing.T) {
+	maps, err := readMappings(strings.NewReader("alma_library_code,alma_location_code,rcr,iln,sudoc_label\n"+
+		"BU_SCI,,100000001,1,BU Sciences\nBU_SCI,RES,100000002,1,Réserve\n"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := Controller{Config: &Config{ExceptionsMode: ExceptionsSuppress}, Mappings: maps}
+	items := []*entities.AlmaItem{{}}
+	record := entities.BibRecord{
+		PPN:            "123456789",
+		SudocLocations: []*entities.SudocLocation{{RCR: "100000002", Name: "UNIV-2"}},
+		AlmaLocations:  []*entities.AlmaLocation{{Library_code: "BU_SCI", Location_code: "LIB", Items: items}},
+	}
+	anomalies := ctrl.Compare(&record)
+	if len(anomalies) != 2 {
+		t.Fatalf("want 2 anomalies, got %+v", anomalies)
+	}
+	if anomalies[0].SudocLib != "Réserve" || anomalies[1].RCR != "100000001" {
+		t.Errorf("unexpected anomalies %+v", anomalies)
+	}
+	record.AlmaLocations[0].Location_code = "RES"
+	if anomalies := ctrl.Compare(&record); len(anomalies) != 0 {
+		t.Errorf("want no anomaly, got %+v", anomalies)
+	}
+}
+
+func TestCompareLocationOnlyMapping(t *testing.T) {
+	maps, err := readMappings(strings.NewReader("alma_library_code,alma_location_code,rcr,iln\n"+
+		"BU_SCI,RES,100000002,1\n"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := Controller{Config: &Config{ExceptionsMode: ExceptionsSuppress}, Mappings: maps}
+	ctrl.getLibs()
+	items := []*entities.AlmaItem{{}}
+	record := entities.BibRecord{
+		PPN:           "123456789",
+		AlmaLocations: []*entities.AlmaLocation{{Library_code: "BU_SCI", Location_code: "LIB", Items: items}},
+	}
+	record.Filter(nil, ctrl.Config.FolowedLibs, nil)
+	anomalies := ctrl.Compare(&record)
+	if len(anomalies) != 1 || anomalies[0].RCR != "" || anomalies[0].ILN != "" || anomalies[0].AlmaLibCode != "BU_SCI" {
+		t.Errorf("want an anomaly without RCR, got %+v", anomalies)
+	}
+
+	ctrl.filter(&record)
+	if len(record.AlmaLocations) != 0 || len(record.Filtered) != 1 {
+		t.Errorf("want the location filtered, got %+v", record)
+	}
+}
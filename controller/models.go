@@ -2,52 +2,107 @@ package controller
 
 import (
 	"casl/entities"
+	"casl/exl"
+	"casl/marc"
+	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 )
 
-type suClient interface {
+// SudocAPI is the part of the SUDOC client used by the controller.
+type SudocAPI interface {
+	GetRecord(ppn string) (*marc.Record, error)
+	GetRecordContext(ctx context.Context, ppn string) (*marc.Record, error)
 	GetLocations(ppn string) ([]*entities.SudocLocation, error)
+	GetLocationsContext(ctx context.Context, ppn string) ([]*entities.SudocLocation, error)
+	RecordLocations(ppn string, record *marc.Record) ([]*entities.SudocLocation, error)
+	RecordLocationsContext(ctx context.Context, ppn string, record *marc.Record) ([]*entities.SudocLocation, error)
 	GetFilteredLocations(ppn string, rcrs []string) ([]*entities.SudocLocation, error)
 	Stats(t string) int
 	GetFollowedRCRs() []string
+	GetLibrary(rcr string) (iln, name string, err error)
+	GetLibraryContext(ctx context.Context, rcr string) (iln, name string, err error)
 }
 
-type almaClient interface {
+// AlmaAPI is the part of the Alma client used by the controller.
+type AlmaAPI interface {
 	GetLocations(ppn string) ([]*entities.AlmaLocation, error)
+	GetLocationsContext(ctx context.Context, ppn string) ([]*entities.AlmaLocation, error)
 	GetFilteredLocations(ppn string, lib_codes []string, ignored_locataions []string) ([]*entities.AlmaLocation, error)
+	GetBib(mms string) (*marc.Record, error)
+	GetBibContext(ctx context.Context, mms string) (*marc.Record, error)
+	GetLibraries() ([]exl.Library, error)
+	GetLibrariesContext(ctx context.Context) ([]exl.Library, error)
 	Stats(t string) int
 }
 
 type Controller struct {
-	Config     *config
-	Mappings   *mappings
-	SUClient   suClient
-	AlmaClient almaClient
+	Config     *Config
+	Mappings   *Mappings
+	SUClient   SudocAPI
+	AlmaClient AlmaAPI
+	Output     OutputOptions
+	Exceptions []*Exception
+	// BeforeFetch and OnRecord, if set, are called by Run before and after
+	// each fetch, with the index of the PPN; OnRecord gets the context of the
+	// requests and the error of Fetch.
+	BeforeFetch func(i int, ppn string)
+	OnRecord    func(ctx context.Context, i int, record entities.BibRecord, err error)
 }
 
 // TODO: add a Filter struct to contain all filters
 // TODO: add a filter for ignored alma status ("ACQ")
-type config struct {
+type Config struct {
 	MappingFilePath string   `json:"alma-rcr_file_path"`
 	AlmaAPIKey      string   `json:"alma_api_key"`
 	ILNs            []string `json:"iln_to_track"`
 	IgnoredAlmaColl []string `json:"ignored_alma_collections"`
 	IgnoredSudocRCR []string `json:"ignored_sudoc_rcr"`
 	MonolithicRCR   []string `json:"monolithic_rcr"`
-	FollowedRCR     []string
-	FolowedLibs     []string
+	AlmaBibURL      string   `json:"alma_bib_url"`
+	ExceptionsFile  string   `json:"exceptions_file_path"`
+	ExceptionsMode  string   `json:"exceptions_mode"`
+	// BibLevels are the bibliographic levels (leader/07) of the records to
+	// check, all if empty. SkipElectronic skips electronic resources.
+	BibLevels      []string `json:"bibliographic_levels"`
+	SkipElectronic bool     `json:"skip_electronic_resources"`
+	FollowedRCR    []string
+	FolowedLibs    []string
 }
 
 // Mappings Alma/RCR, Alma/Libraries names, RCR/ILN, RCR/label, read from CSV.
-type mappings struct {
+type Mappings struct {
+	// version is the format of the mapping file.
+	version int
+	rows    []MappingRow
+	// alma2rcr maps libraries, loc2rcr locations given as "library/location".
 	alma2rcr map[string][]string
+	loc2rcr  map[string][]string
 	rcr2iln  map[string]string
 	alma2str map[string]string
 	rcr2str  map[string]string
 	rcr2alma map[string][]string
 }
 
+// MappingRow is a line of the alma-rcr mapping file.
+type MappingRow struct {
+	AlmaName string
+	AlmaCode string
+	// AlmaLocation restricts the mapping to a location of the library.
+	AlmaLocation string
+	RCR          string
+	ILN          string
+	SudocName    string
+	// ValidFrom and ValidTo are the first and last days when the mapping
+	// applies, unbounded if zero.
+	ValidFrom time.Time
+	ValidTo   time.Time
+	Comment   string
+	Line      int
+}
+
 func (c Controller) String() string {
 	var sb strings.Builder
 	fmt.Fprintln(&sb, c.Config)
@@ -58,7 +113,7 @@ func (c Controller) String() string {
 	return sb.String()
 }
 
-func (c config) String() string {
+func (c Config) String() string {
 	var sb strings.Builder
 	fmt.Fprintf(&sb, "*** CONFIG\n\n")
 	fmt.Fprintf(&sb, "Mapping file path: %s\n", c.MappingFilePath)
@@ -67,17 +122,23 @@ func (c config) String() string {
 	fmt.Fprintf(&sb, "Alma collections to ignore: %v\n", c.IgnoredAlmaColl)
 	fmt.Fprintf(&sb, "RCR to ignore: %v\n", c.IgnoredSudocRCR)
 	fmt.Fprintf(&sb, "RCR with sublocations: %v\n", c.MonolithicRCR)
+	fmt.Fprintf(&sb, "Alma bib URL: %s\n", c.AlmaBibURL)
+	fmt.Fprintf(&sb, "Exceptions file: %s (%s)\n", c.ExceptionsFile, c.ExceptionsMode)
 	fmt.Fprintf(&sb, "RCR to inspect: %v\n", c.FollowedRCR)
 	return sb.String()
 }
 
-func (m mappings) String() string {
+func (m Mappings) String() string {
 	var sb strings.Builder
 	fmt.Fprintf(&sb, "*** MAPPINGS\n\n")
 	fmt.Fprintln(&sb, "-- Alma -> RCR:")
 	for k, v := range m.alma2rcr {
 		fmt.Fprintf(&sb, "%s -> %v\n", k, v)
 	}
+	fmt.Fprintln(&sb, "\n-- Alma location -> RCR:")
+	for k, v := range m.loc2rcr {
+		fmt.Fprintf(&sb, "%s -> %v\n", k, v)
+	}
 	fmt.Fprintln(&sb, "\n-- RCR -> Alma:")
 	for k, v := range m.rcr2alma {
 		fmt.Fprintf(&sb, "%s -> %v\n", k, v)
@@ -96,3 +157,15 @@ func (m mappings) String() string {
 	}
 	return sb.String()
 }
+
+// ConfigSnapshot returns the configuration as JSON, without the Alma API key.
+func (c Controller) ConfigSnapshot() ([]byte, error) {
+	if c.Config == nil {
+		return []byte("{}"), nil
+	}
+	conf := *c.Config
+	if conf.AlmaAPIKey != "" {
+		conf.AlmaAPIKey = "***"
+	}
+	return json.Marshal(conf)
+}
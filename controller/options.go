@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"casl/entities"
+	"casl/exl"
+	"casl/requests"
+	"casl/sudoc"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"time"
+)
+
+// Option configures a controller created by New.
+type Option func(*options)
+
+type options struct {
+	config     *Config
+	configFile string
+	mappings   io.Reader
+	sudoc      SudocAPI
+	alma       AlmaAPI
+	fetcher    requests.Fetcher
+	connect    bool
+	ctx        context.Context
+	// optionalMappings tolerates a missing mapping file, noExceptions does not
+	// load the exceptions file.
+	optionalMappings bool
+	noExceptions     bool
+}
+
+// WithConfig uses a copy of the given configuration. Relative paths of the
+// files it refers to are relative to the working directory.
+func WithConfig(conf *Config) Option {
+	return func(o *options) {
+		o.config = conf
+	}
+}
+
+// WithConfigFile reads the configuration from a JSON file. Relative paths of
+// the files it refers to are resolved from the directory of the file.
+func WithConfigFile(filename string) Option {
+	return func(o *options) {
+		o.configFile = filename
+	}
+}
+
+// WithMappings reads the alma-rcr mapping from r instead of the file of the
+// configuration.
+func WithMappings(r io.Reader) Option {
+	return func(o *options) {
+		o.mappings = r
+	}
+}
+
+// WithSudocClient uses the given SUDOC client.
+func WithSudocClient(c SudocAPI) Option {
+	return func(o *options) {
+		o.sudoc = c
+	}
+}
+
+// WithAlmaClient uses the given Alma client.
+func WithAlmaClient(c AlmaAPI) Option {
+	return func(o *options) {
+		o.alma = c
+	}
+}
+
+// WithFetcher creates the SUDOC and Alma clients which are not given, with the
+// fetcher.
+func WithFetcher(fetcher requests.Fetcher) Option {
+	return func(o *options) {
+		o.fetcher = fetcher
+		o.connect = true
+	}
+}
+
+// WithContext sets the context of the requests made by New to create the
+// clients.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// withOptionalMappings tolerates a missing mapping file.
+func withOptionalMappings() Option {
+	return func(o *options) {
+		o.optionalMappings = true
+	}
+}
+
+// withoutExceptions does not load the exceptions file.
+func withoutExceptions() Option {
+	return func(o *options) {
+		o.noExceptions = true
+	}
+}
+
+// New creates a controller from a configuration, given by WithConfig or
+// WithConfigFile, and an alma-rcr mapping, read from WithMappings or else from
+// the file of the configuration. Without clients, given or created by
+// WithFetcher, the controller can write reports but not fetch locations.
+func New(opts ...Option) (*Controller, error) {
+	o := options{ctx: context.Background()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ctrl := &Controller{}
+	if o.config != nil {
+		ctrl.Config = o.config.clone()
+	}
+	if o.configFile != "" {
+		if err := ctrl.loadConfig(o.configFile); err != nil {
+			return nil, err
+		}
+	}
+	if ctrl.Config == nil {
+		return nil, errors.New("New: no configuration")
+	}
+
+	switch {
+	case o.mappings != nil:
+		maps, err := readMappings(o.mappings, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("New: mapping: %w", err)
+		}
+		ctrl.Mappings = maps
+	case ctrl.Config.MappingFilePath != "":
+		err := ctrl.getMappingsFromCSV(ctrl.Config.MappingFilePath)
+		if o.optionalMappings && errors.Is(err, fs.ErrNotExist) {
+			ctrl.Mappings = &Mappings{}
+		} else if err != nil {
+			return nil, err
+		}
+	case o.optionalMappings:
+		ctrl.Mappings = &Mappings{}
+	default:
+		return nil, errors.New("New: no alma-rcr mapping")
+	}
+	ctrl.getLibs()
+
+	switch ctrl.Config.ExceptionsMode {
+	case "":
+		ctrl.Config.ExceptionsMode = ExceptionsSuppress
+	case ExceptionsSuppress, ExceptionsTag:
+	default:
+		return nil, fmt.Errorf("New: unknown exceptions mode %q", ctrl.Config.ExceptionsMode)
+	}
+	if ctrl.Config.ExceptionsFile != "" && !o.noExceptions {
+		if err := ctrl.loadExceptions(ctrl.Config.ExceptionsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	ctrl.SUClient, ctrl.AlmaClient = o.sudoc, o.alma
+	if o.connect && ctrl.SUClient == nil {
+		suclient, err := sudoc.NewSudocClientContext(o.ctx, ctrl.Config.ILNs, o.fetcher)
+		if err != nil {
+			return nil, err
+		}
+		ctrl.SUClient = suclient
+	}
+	if o.connect && ctrl.AlmaClient == nil {
+		almaclient, err := exl.NewAlmaClient(ctrl.Config.AlmaAPIKey, "", o.fetcher)
+		if err != nil {
+			return nil, err
+		}
+		ctrl.AlmaClient = almaclient
+	}
+	if ctrl.SUClient != nil {
+		ctrl.Config.FollowedRCR = ctrl.SUClient.GetFollowedRCRs()
+	}
+	return ctrl, nil
+}
+
+// clone returns a deep copy of the configuration, which the controller
+// completes without changing the caller's one.
+func (c *Config) clone() *Config {
+	conf := *c
+	for _, s := range []*[]string{&conf.ILNs, &conf.IgnoredAlmaColl, &conf.IgnoredSudocRCR, &conf.MonolithicRCR,
+		&conf.BibLevels, &conf.FollowedRCR, &conf.FolowedLibs} {
+		*s = slices.Clone(*s)
+	}
+	return &conf
+}
+
+// RunResult is the outcome of Run.
+type RunResult struct {
+	// Records are the records checked, with their filtered locations.
+	Records []entities.BibRecord
+	// Anomalies are the anomalies of the records, once the exceptions are
+	// applied.
+	Anomalies []Summary
+	// Skipped are the PPNs of the records which must not be checked.
+	Skipped []string
+	// Errors are those of the records which could not be fetched.
+	Errors []error
+}
+
+// Run fetches and compares the locations of the PPNs. The BeforeFetch and
+// OnRecord hooks, if any, are called before and after each fetch; OnRecord
+// gets the context of the fetches. When the context is canceled, Run stops
+// dispatching PPNs: the record being fetched is completed, and what was
+// collected is returned with the context error. A deadline of the context
+// interrupts the requests.
+func (ctrl *Controller) Run(ctx context.Context, ppns []string) (*RunResult, error) {
+	if ctrl.SUClient == nil || ctrl.AlmaClient == nil {
+		return nil, errors.New("Run: the controller has no client")
+	}
+	fetchCtx := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithDeadline(fetchCtx, deadline)
+		defer cancel()
+	}
+	res := &RunResult{}
+	for i, ppn := range ppns {
+		if err := ctx.Err(); err != nil {
+			return res, fmt.Errorf("Run: %w", err)
+		}
+		if ctrl.BeforeFetch != nil {
+			ctrl.BeforeFetch(i, ppn)
+		}
+		record, err := ctrl.FetchContext(fetchCtx, ppn)
+		if ctrl.OnRecord != nil {
+			ctrl.OnRecord(fetchCtx, i, record, err)
+		}
+		switch {
+		case errors.Is(err, ErrSkippedRecord):
+			res.Skipped = append(res.Skipped, ppn)
+		case err != nil:
+			res.Errors = append(res.Errors, err)
+		default:
+			res.Records = append(res.Records, record)
+			res.Anomalies = append(res.Anomalies, ctrl.Compare(&record)...)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"casl/entities"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewAndRun(t *testing.T) {
+	items := []*entities.AlmaItem{{Process_code: ""}}
+	mapping := `"BU Sciences",BIB_1,100000001,1
+"BU Droit",BIB_2,200000001,1
+`
+	newCtrl := func(opts ...Option) (*Controller, error) {
+		return New(append([]Option{
+			WithConfig(&Config{ILNs: []string{"1"}}),
+			WithMappings(strings.NewReader(mapping)),
+			WithSudocClient(fakeSudoc{
+				locations: []*entities.SudocLocation{
+					{ILN: "1", RCR: "100000001", EPN: "EP1"},
+					{ILN: "1", RCR: "200000001", EPN: "EP2"},
+				},
+				libraries: map[string][2]string{"100000001": {"1", "BU-1"}, "200000001": {"1", "BU-2"}},
+			}),
+			WithAlmaClient(fakeAlma{locations: []*entities.AlmaLocation{
+				{MMS: "mms_1", Library_code: "BIB_1", Location_code: "LIB", Items: items},
+			}}),
+		}, opts...)...)
+	}
+
+	ctrl, err := newCtrl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ctrl.Config.FollowedRCR) != 2 || ctrl.Config.ExceptionsMode != ExceptionsSuppress {
+		t.Fatalf("unexpected configuration %+v", ctrl.Config)
+	}
+	var calls []string
+	ctrl.BeforeFetch = func(i int, ppn string) { calls = append(calls, "before "+ppn) }
+	ctrl.OnRecord = func(ctx context.Context, i int, record entities.BibRecord, err error) {
+		calls = append(calls, "after "+record.PPN)
+	}
+
+	res, err := ctrl.Run(context.Background(), []string{"111111111", "222222222"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Records) != 2 || len(res.Skipped) != 0 || len(res.Errors) != 0 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	wantCalls := []string{"before 111111111", "after 111111111", "before 222222222", "after 222222222"}
+	if !slices.Equal(calls, wantCalls) {
+		t.Errorf("want hooks %v, got %v", wantCalls, calls)
+	}
+	if len(res.Anomalies) != 2 || res.Anomalies[0].RCR != "200000001" || res.Anomalies[0].Type != MissingInAlma {
+		t.Errorf("want an anomaly of RCR 200000001 per record, got %+v", res.Anomalies)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err = ctrl.Run(ctx, []string{"111111111"})
+	if !errors.Is(err, context.Canceled) || res == nil || len(res.Records) != 0 {
+		t.Errorf("want an empty result and context.Canceled, got %v, %v", res, err)
+	}
+
+	// Canceling stops dispatching, but the current record is completed.
+	ctx, cancel = context.WithCancel(context.Background())
+	ctrl.OnRecord = func(ctx context.Context, i int, record entities.BibRecord, err error) { cancel() }
+	res, err = ctrl.Run(ctx, []string{"111111111", "222222222"})
+	if !errors.Is(err, context.Canceled) || len(res.Records) != 1 || len(res.Anomalies) != 1 {
+		t.Errorf("want the first record and context.Canceled, got %v, %v", res, err)
+	}
+
+	if _, err := New(WithMappings(strings.NewReader(mapping))); err == nil {
+		t.Error("want error without configuration")
+	}
+	if _, err := New(WithConfig(&Config{})); err == nil {
+		t.Error("want error without mapping")
+	}
+	if _, err := newCtrl(WithMappings(strings.NewReader("BIB_1,100000001\n"))); err == nil {
+		t.Error("want error on an invalid mapping")
+	}
+	offline, err := New(WithConfig(&Config{}), WithMappings(strings.NewReader(mapping)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := offline.Run(context.Background(), []string{"111111111"}); err == nil {
+		t.Error("want error without clients")
+	}
+
+	// The controller completes a copy of the caller's configuration.
+	conf := &Config{ILNs: []string{"1"}}
+	copied, err := New(WithConfig(conf), WithMappings(strings.NewReader(mapping)),
+		WithSudocClient(fakeSudoc{libraries: map[string][2]string{"100000001": {"1", "BU-1"}}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied.Config.ILNs[0] = "2"
+	if conf.ExceptionsMode != "" || conf.FollowedRCR != nil || conf.FolowedLibs != nil || conf.ILNs[0] != "1" {
+		t.Errorf("the caller's configuration was changed: %+v", conf)
+	}
+}
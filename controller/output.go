@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Output formats accepted by WriteResults.
+const (
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+	FormatXLSX  = "xlsx"
+	FormatHTML  = "html"
+)
+
+// SchemaVersion is the version of the JSON and JSON Lines output schema. It is
+// incremented whenever a field is removed or its meaning changes.
+const SchemaVersion = 1
+
+// Formats lists all the supported output formats.
+var Formats = []string{FormatCSV, FormatJSON, FormatJSONL, FormatXLSX, FormatHTML}
+
+// jsonReport is the top-level document of the JSON output.
+type jsonReport struct {
+	SchemaVersion int       `json:"schema_version"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	Count         int       `json:"count"`
+	Anomalies     []Summary `json:"anomalies"`
+}
+
+// WriteResults writes the anomalies in the given format into a new file named
+// after the current time, in the output directory, and returns its name.
+func (ctrl *Controller) WriteResults(results []Summary, format string) (string, error) {
+	if !slices.Contains(Formats, format) {
+		return "", fmt.Errorf("WriteResults: unknown format %q", format)
+	}
+
+	filename := filepath.Join(ctrl.Output.Dir, resultsFilename(time.Now(), format))
+	if err := ctrl.writeFile(filename, results, format); err != nil {
+		return "", fmt.Errorf("WriteResults: %w", err)
+	}
+	return filename, nil
+}
+
+// encode writes the results in the given format.
+func (ctrl *Controller) encode(w io.Writer, results []Summary, format string) error {
+	switch format {
+	case FormatCSV:
+		return encodeCSV(w, results)
+	case FormatJSON:
+		return encodeJSON(w, results)
+	case FormatJSONL:
+		return encodeJSONL(w, results)
+	case FormatXLSX:
+		return ctrl.encodeXLSX(w, results)
+	case FormatHTML:
+		return ctrl.encodeHTML(w, results)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// resultsFilename returns the name of the results file, eg
+// resultats_20240131-154500.csv, or of the results directory if ext is empty.
+func resultsFilename(t time.Time, ext string) string {
+	format := fmt.Sprintf("%d%02d%02d-%02d%02d%02d", t.Year(), t.Month(), t.Day(),
+		t.Hour(), t.Minute(), t.Second())
+	if ext == "" {
+		return "resultats_" + format
+	}
+	return "resultats_" + format + "." + ext
+}
+
+func encodeCSV(w io.Writer, results []Summary) error {
+	var records [][]string
+	records = append(records, []string{"PPN", "ILN", "Bibliothèque Alma",
+		"Bibliothèque SUDOC", "RCR"})
+
+	for _, res := range results {
+		records = append(records, res.toCSV())
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+func encodeJSON(w io.Writer, results []Summary) error {
+	report := jsonReport{
+		SchemaVersion: SchemaVersion,
+		GeneratedAt:   time.Now(),
+		Count:         len(results),
+		Anomalies:     results,
+	}
+	if report.Anomalies == nil {
+		report.Anomalies = []Summary{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// encodeJSONL writes one anomaly per line.
+func encodeJSONL(w io.Writer, results []Summary) error {
+	enc := json.NewEncoder(w)
+	for _, res := range results {
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func provideSummaries() []Summary {
+	checked := time.Date(2024, 1, 31, 15, 45, 0, 0, time.UTC)
+	return []Summary{
+		{Type: MissingInAlma, ILN: "1", RCR: "100000001", PPN: "123456789",
+			EPN: "EX1", SudocLib: "UNIV-1.1", CallNumber: "823.9 WOO", CheckedAt: checked},
+		{Type: MissingInSudoc, ILN: "2", RCR: "200000001", PPN: "98765432X",
+			MMS: "mms_1", AlmaLib: "Bibliothèque 1", AlmaLibCode: "BIB_1",
+			AlmaLocation: "LOC_1", CallNumber: "CN_1", CheckedAt: checked},
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	want := provideSummaries()
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+
+	var got jsonReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.SchemaVersion != SchemaVersion || got.Count != len(want) {
+		t.Errorf("got schema_version = %d, count = %d, want %d, %d",
+			got.SchemaVersion, got.Count, SchemaVersion, len(want))
+	}
+	if !reflect.DeepEqual(got.Anomalies, want) {
+		t.Errorf("want %v, got %v", want, got.Anomalies)
+	}
+
+	buf.Reset()
+	if err := encodeJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"anomalies": []`) {
+		t.Errorf("empty results should be encoded as an empty array, got %s", buf.String())
+	}
+}
+
+func TestEncodeJSONL(t *testing.T) {
+	want := provideSummaries()
+	var buf bytes.Buffer
+	if err := encodeJSONL(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Summary
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var s Summary
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, s)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestResultsFilename(t *testing.T) {
+	date := time.Date(2024, 1, 31, 15, 4, 5, 0, time.UTC)
+	want := "resultats_20240131-150405.jsonl"
+	if got := resultsFilename(date, FormatJSONL); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
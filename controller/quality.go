@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"casl/entities"
+	"casl/marc"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QualityFinding is a structural problem of the SUDOC record of a PPN.
+type QualityFinding struct {
+	PPN string
+	marc.Finding
+}
+
+var findingLabels = map[marc.FindingKind]string{
+	marc.FindingUnknownFormat:    "format inconnu",
+	marc.FindingInvalidLeader:    "label invalide",
+	marc.FindingMissingField:     "zone obligatoire absente",
+	marc.FindingRepeatedField:    "zone non répétable répétée",
+	marc.FindingUnknownField:     "zone inconnue",
+	marc.FindingFieldType:        "zone de contrôle ou de données attendue",
+	marc.FindingInvalidIndicator: "indicateur invalide",
+	marc.FindingEmptyField:       "zone sans sous-zone",
+	marc.FindingUnknownSubfield:  "sous-zone inconnue",
+	marc.FindingMissingSubfield:  "sous-zone obligatoire absente",
+	marc.FindingRepeatedSubfield: "sous-zone non répétable répétée",
+}
+
+var qualityHeader = []string{"ppn", "tag", "occurrence", "code", "kind", "label", "message"}
+
+// ValidateRecord checks the structure of the SUDOC record of a fetched
+// record, which is fetched again only if the record does not hold it.
+func (ctrl *Controller) ValidateRecord(ctx context.Context, record entities.BibRecord) ([]QualityFinding, error) {
+	sudoc, err := ctrl.sudocRecord(ctx, record)
+	if err != nil {
+		return nil, fmt.Errorf("ValidateRecord: %w", err)
+	}
+	var findings []QualityFinding
+	for _, f := range marc.Validate(sudoc) {
+		findings = append(findings, QualityFinding{record.PPN, f})
+	}
+	return findings, nil
+}
+
+// sudocRecord returns the SUDOC record held by a record, or fetches it.
+func (ctrl *Controller) sudocRecord(ctx context.Context, record entities.BibRecord) (*marc.Record, error) {
+	if record.Sudoc != nil {
+		return record.Sudoc, nil
+	}
+	return ctrl.SUClient.GetRecordContext(ctx, record.PPN)
+}
+
+// WriteQuality writes the findings into a CSV file in the output directory
+// and returns its name.
+func (ctrl *Controller) WriteQuality(findings []QualityFinding) (string, error) {
+	rows := [][]string{qualityHeader}
+	for _, f := range findings {
+		label := findingLabels[f.Kind]
+		if label == "" {
+			label = string(f.Kind)
+		}
+		rows = append(rows, []string{f.PPN, f.Tag, strconv.Itoa(f.Occurrence), f.Code,
+			string(f.Kind), label, f.Message})
+	}
+
+	filename := filepath.Join(ctrl.Output.Dir,
+		"qualite"+strings.TrimPrefix(resultsFilename(time.Now(), FormatCSV), "resultats"))
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", fmt.Errorf("WriteQuality: %w", err)
+	}
+	defer f.Close()
+	if err := csv.NewWriter(f).WriteAll(rows); err != nil {
+		return "", fmt.Errorf("WriteQuality: %w", err)
+	}
+	return filename, f.Close()
+}
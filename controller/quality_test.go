@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"casl/entities"
+	"casl/marc"
+	"context"
+	"encoding/csv"
+	"os"
+	"testing"
+)
+
+func TestQuality(t *testing.T) {
+	record := &marc.Record{
+		Leader:        "     nam0 22        450 ",
+		Controlfields: []marc.Controlfield{{Tag: "001", Value: "123456789"}},
+		Datafields: []marc.Datafield{
+			{Tag: "100", Subfields: []marc.Subfield{{Code: "a", Value: "20130626d1928    m  y0frey50      ba"}}},
+			{Tag: "200", Ind1: "1", Subfields: []marc.Subfield{{Code: "a", Value: "Orlando"}}},
+			{Tag: "930", Subfields: []marc.Subfield{{Code: "5", Value: "100000001:EP1"}, {Code: "5", Value: "200000001:EP2"}}},
+		},
+	}
+	ctrl := Controller{SUClient: fakeSudoc{record: record}, Output: OutputOptions{Dir: t.TempDir()}}
+	findings, err := ctrl.ValidateRecord(context.Background(), entities.BibRecord{PPN: "123456789"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].PPN != "123456789" || findings[0].Kind != marc.FindingRepeatedSubfield {
+		t.Fatalf("want a repeated 930$5, got %v", findings)
+	}
+	// The record held by a fetched record is validated, not fetched again.
+	empty := Controller{SUClient: fakeSudoc{}}
+	held, err := empty.ValidateRecord(context.Background(), entities.BibRecord{PPN: "123456789", Sudoc: record})
+	if err != nil || len(held) != 1 {
+		t.Errorf("want the finding of the held record, got %v, %v", held, err)
+	}
+
+	filename, err := ctrl.WriteQuality(findings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1][1] != "930" || rows[1][3] != "5" || rows[1][5] != "sous-zone non répétable répétée" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
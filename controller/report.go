@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Keys used to split the results by library.
+const (
+	GroupByILN  = "iln"
+	GroupByRCR  = "rcr"
+	GroupByAlma = "alma"
+)
+
+// GroupKeys lists all the supported grouping keys.
+var GroupKeys = []string{GroupByILN, GroupByRCR, GroupByAlma}
+
+const sudocURL = "https://www.sudoc.fr/"
+
+// OutputOptions holds the output settings which are not part of the
+// configuration file.
+type OutputOptions struct {
+	// GroupBy selects how results are split in reports: GroupByILN,
+	// GroupByRCR or GroupByAlma.
+	GroupBy string
+	// Dir is the directory where the results are written, the current one if
+	// empty.
+	Dir string
+}
+
+// Label returns the french name of the anomaly type, used in reports.
+func (t AnomalyType) Label() string {
+	switch t {
+	case MissingInAlma:
+		return "Absent d'Alma"
+	case MissingInSudoc:
+		return "Absent du SUDOC"
+	default:
+		return string(t)
+	}
+}
+
+// group is a subset of the results sharing the same ILN, RCR or Alma library.
+type group struct {
+	Key     string
+	Label   string
+	Results []Summary
+}
+
+// Count returns the number of anomalies of the given type in the group.
+func (g group) Count(t AnomalyType) int {
+	n := 0
+	for _, res := range g.Results {
+		if res.Type == t {
+			n++
+		}
+	}
+	return n
+}
+
+// groupResults splits the results by ILN, RCR or Alma library, sorted by key.
+// A location missing in Alma belongs to every Alma library mapped to its RCR.
+func (ctrl *Controller) groupResults(results []Summary, by string) []group {
+	index := make(map[string]int)
+	var groups []group
+	for _, res := range results {
+		for _, key := range ctrl.groupKeys(res, by) {
+			i, ok := index[key]
+			if !ok {
+				i = len(groups)
+				index[key] = i
+				groups = append(groups, group{Key: key, Label: ctrl.groupLabel(key, by, res)})
+			}
+			groups[i].Results = append(groups[i].Results, res)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Key < groups[j].Key
+	})
+	return groups
+}
+
+func (ctrl *Controller) groupKeys(res Summary, by string) []string {
+	switch by {
+	case GroupByRCR:
+		return []string{res.RCR}
+	case GroupByAlma:
+		// Followed RCRs come from iln2rcr: a RCR may have no active mapping,
+		// its anomalies are then grouped under an empty key.
+		if res.Type == MissingInAlma && ctrl.Mappings != nil {
+			if codes := ctrl.Mappings.rcr2alma[res.RCR]; len(codes) > 0 {
+				return codes
+			}
+		}
+		return []string{res.AlmaLibCode}
+	default:
+		return []string{res.ILN}
+	}
+}
+
+// groupLabel returns a human readable name for an ILN, a RCR or an Alma
+// library, or an empty string if none is known.
+func (ctrl *Controller) groupLabel(key, by string, res Summary) string {
+	switch by {
+	case GroupByRCR:
+		return ctrl.rcrLabel(key)
+	case GroupByAlma:
+		if key == "" {
+			return "Sans bibliothèque Alma"
+		}
+		if ctrl.Mappings != nil && ctrl.Mappings.alma2str[key] != "" {
+			return ctrl.Mappings.alma2str[key]
+		}
+		return res.AlmaLib
+	default:
+		return "ILN " + key
+	}
+}
+
+// rcrLabel returns the SUDOC name of a RCR, or else the names of the Alma
+// libraries mapped to it, or an empty string.
+func (ctrl *Controller) rcrLabel(key string) string {
+	if ctrl.Mappings == nil {
+		return ""
+	}
+	if name := ctrl.Mappings.rcr2str[key]; name != "" {
+		return name
+	}
+	var names []string
+	for _, code := range ctrl.Mappings.rcr2alma[key] {
+		names = append(names, ctrl.Mappings.alma2str[code])
+	}
+	return strings.Join(names, " / ")
+}
+
+// almaURL returns the link to the bib record in Alma, or an empty string if
+// there is no MMS or no URL configured.
+func (ctrl *Controller) almaURL(mms string) string {
+	if mms == "" || ctrl.Config == nil || ctrl.Config.AlmaBibURL == "" {
+		return ""
+	}
+	return strings.ReplaceAll(ctrl.Config.AlmaBibURL, "{mms}", mms)
+}
+
+// groupTitle returns the column header of the grouping key.
+func groupTitle(by string) string {
+	switch by {
+	case GroupByRCR:
+		return "RCR"
+	case GroupByAlma:
+		return "Code Alma"
+	default:
+		return "ILN"
+	}
+}
+
+func (ctrl *Controller) groupBy() string {
+	if ctrl.Output.GroupBy == "" {
+		return GroupByILN
+	}
+	return ctrl.Output.GroupBy
+}
+
+// encodeXLSX writes a workbook made of a summary sheet followed by one sheet
+// per ILN, per RCR or per Alma library.
+func (ctrl *Controller) encodeXLSX(w io.Writer, results []Summary) error {
+	by := ctrl.groupBy()
+	groups := ctrl.groupResults(results, by)
+	used := make(map[string]bool)
+
+	summary := xlsxSheet{name: xlsxSheetName("Synthèse", used)}
+	summary.rows = append(summary.rows, textCells(groupTitle(by), "Intitulé",
+		MissingInAlma.Label(), MissingInSudoc.Label(), "Total"))
+	var inAlma, inSudoc int
+	for _, g := range groups {
+		a, s := g.Count(MissingInAlma), g.Count(MissingInSudoc)
+		inAlma += a
+		inSudoc += s
+		summary.rows = append(summary.rows, []xlsxCell{{value: g.Key}, {value: g.Label},
+			numberCell(a), numberCell(s), numberCell(len(g.Results))})
+	}
+	summary.rows = append(summary.rows, []xlsxCell{{value: "Total"}, {},
+		numberCell(inAlma), numberCell(inSudoc), numberCell(len(results))})
+
+	sheets := []xlsxSheet{summary}
+	for _, g := range groups {
+		// Without a known label, the sheet is named after the key alone.
+		name := g.Key
+		if by != GroupByILN && g.Label != "" {
+			name += " " + g.Label
+		}
+		sheet := xlsxSheet{name: xlsxSheetName(name, used)}
+		sheet.rows = append(sheet.rows, textCells("Anomalie", "PPN", "ILN", "RCR",
+			"Bibliothèque SUDOC", "EPN", "Bibliothèque Alma", "Code Alma",
+			"Localisation Alma", "Cote", "MMS", "Date", "Exception"))
+		for _, res := range g.Results {
+			checked := ""
+			if !res.CheckedAt.IsZero() {
+				checked = res.CheckedAt.Format("2006-01-02 15:04:05")
+			}
+			sheet.rows = append(sheet.rows, []xlsxCell{
+				{value: res.Type.Label()},
+				{value: res.PPN, link: sudocURL + res.PPN},
+				{value: res.ILN},
+				{value: res.RCR},
+				{value: res.SudocLib},
+				{value: res.EPN},
+				{value: res.AlmaLib},
+				{value: res.AlmaLibCode},
+				{value: res.AlmaLocation},
+				{value: res.CallNumber},
+				{value: res.MMS, link: ctrl.almaURL(res.MMS)},
+				{value: checked},
+				{value: acknowledgement(res)},
+			})
+		}
+		sheets = append(sheets, sheet)
+	}
+	return writeXLSX(w, sheets)
+}
+
+// acknowledgement describes the exception matching the anomaly, if any.
+func acknowledgement(s Summary) string {
+	if !s.Acknowledged {
+		return ""
+	}
+	if s.Comment == "" {
+		return "oui"
+	}
+	return s.Comment
+}
+
+func textCells(values ...string) []xlsxCell {
+	cells := make([]xlsxCell, len(values))
+	for i, v := range values {
+		cells[i] = xlsxCell{value: v}
+	}
+	return cells
+}
+
+func numberCell(n int) xlsxCell {
+	return xlsxCell{value: strconv.Itoa(n), number: true}
+}
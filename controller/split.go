@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// IndexFilename is the name of the file listing the split results.
+const IndexFilename = "index.csv"
+
+// WriteSplitResults writes the anomalies in the given format, one file per
+// ILN, RCR or Alma library according to the GroupBy output option, into a new
+// directory named after the current time. An index file summarises the
+// number of anomalies of each file. It returns the name of the directory.
+func (ctrl *Controller) WriteSplitResults(results []Summary, format string) (string, error) {
+	if !slices.Contains(Formats, format) {
+		return "", fmt.Errorf("WriteSplitResults: unknown format %q", format)
+	}
+	by := ctrl.groupBy()
+
+	dir := filepath.Join(ctrl.Output.Dir, resultsFilename(time.Now(), ""))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("WriteSplitResults: %w", err)
+	}
+
+	index := [][]string{{"Fichier", groupTitle(by), "Bibliothèque",
+		MissingInAlma.Label(), MissingInSudoc.Label(), "Total"}}
+	used := make(map[string]bool)
+	for _, g := range ctrl.groupResults(results, by) {
+		name := splitFilename(g, format, used)
+		if err := ctrl.writeFile(filepath.Join(dir, name), g.Results, format); err != nil {
+			return "", fmt.Errorf("WriteSplitResults: %w", err)
+		}
+		index = append(index, []string{name, g.Key, g.Label,
+			strconv.Itoa(g.Count(MissingInAlma)), strconv.Itoa(g.Count(MissingInSudoc)),
+			strconv.Itoa(len(g.Results))})
+	}
+
+	f, err := os.Create(filepath.Join(dir, IndexFilename))
+	if err != nil {
+		return "", fmt.Errorf("WriteSplitResults: %w", err)
+	}
+	defer f.Close()
+	if err := csv.NewWriter(f).WriteAll(index); err != nil {
+		return "", fmt.Errorf("WriteSplitResults: %w", err)
+	}
+	return dir, f.Close()
+}
+
+func (ctrl *Controller) writeFile(filename string, results []Summary, format string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := ctrl.encode(f, results, format); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// splitFilename builds a file name from the key and the name of the library,
+// eg 123456789_BU_Sciences.csv.
+func splitFilename(g group, ext string, used map[string]bool) string {
+	base := sanitizeFilename(g.Key)
+	if label := sanitizeFilename(g.Label); label != "" && label != base {
+		base = strings.TrimPrefix(base+"_"+label, "_")
+	}
+	if base == "" {
+		base = "sans_bibliotheque"
+	}
+	name := base + "." + ext
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		name = fmt.Sprintf("%s_%d.%s", base, i, ext)
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// sanitizeFilename keeps letters and digits and replaces anything else with
+// underscores.
+func sanitizeFilename(s string) string {
+	var sb strings.Builder
+	underscore := false
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			sb.WriteRune(r)
+			underscore = false
+		} else if !underscore && sb.Len() > 0 {
+			sb.WriteRune('_')
+			underscore = true
+		}
+	}
+	return strings.TrimRight(sb.String(), "_")
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"BU Sciences", "BU_Sciences"},
+		{"Bibliothèque d'Études / Lettres", "Bibliothèque_d_Études_Lettres"},
+		{"../../etc", "etc"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := sanitizeFilename(test.input); got != test.want {
+			t.Errorf("sanitizeFilename(%q): want %q, got %q", test.input, test.want, got)
+		}
+	}
+}
+
+func TestWriteSplitResults(t *testing.T) {
+	ctrl := Controller{
+		Mappings: &Mappings{
+			rcr2alma: map[string][]string{"100000001": {"BIB_1", "BIB_2"}},
+			alma2str: map[string]string{"BIB_1": "Bibliothèque 1", "BIB_2": "Bibliothèque 2"},
+		},
+		Output: OutputOptions{GroupBy: GroupByAlma, Dir: t.TempDir()},
+	}
+	dir, err := ctrl.WriteSplitResults(provideSummaries(), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, IndexFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	index, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Fichier", "Code Alma", "Bibliothèque", "Absent d'Alma", "Absent du SUDOC", "Total"},
+		{"BIB_1_Bibliothèque_1.csv", "BIB_1", "Bibliothèque 1", "1", "1", "2"},
+		{"BIB_2_Bibliothèque_2.csv", "BIB_2", "Bibliothèque 2", "1", "0", "1"},
+	}
+	if !reflect.DeepEqual(index, want) {
+		t.Errorf("want %v, got %v", want, index)
+	}
+	for _, row := range want[1:] {
+		if _, err := os.Stat(filepath.Join(dir, row[0])); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestWriteSplitResultsUnmappedRCR(t *testing.T) {
+	// 100000001 is followed through iln2rcr but has no mapping.
+	ctrl := Controller{
+		Mappings: &Mappings{alma2str: map[string]string{"BIB_1": "Bibliothèque 1"}},
+		Output:   OutputOptions{GroupBy: GroupByAlma, Dir: t.TempDir()},
+	}
+	dir, err := ctrl.WriteSplitResults(provideSummaries(), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(dir, IndexFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	index, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Fichier", "Code Alma", "Bibliothèque", "Absent d'Alma", "Absent du SUDOC", "Total"},
+		{"Sans_bibliothèque_Alma.csv", "", "Sans bibliothèque Alma", "1", "0", "1"},
+		{"BIB_1_Bibliothèque_1.csv", "BIB_1", "Bibliothèque 1", "0", "1", "1"},
+	}
+	if !reflect.DeepEqual(index, want) {
+		t.Errorf("want %v, got %v", want, index)
+	}
+}
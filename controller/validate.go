@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var rcrPattern = regexp.MustCompile(`^[0-9]{8}[0-9X]$`)
+
+// MappingRows returns the lines of the alma-rcr mapping file, in file order.
+func (ctrl *Controller) MappingRows() []MappingRow {
+	if ctrl.Mappings == nil {
+		return nil
+	}
+	return ctrl.Mappings.rows
+}
+
+// ValidateConfig checks the loaded configuration and returns every problem
+// found. The files it refers to have already been read by
+// NewOfflineController.
+func (ctrl *Controller) ValidateConfig() []error {
+	var errs []error
+	conf := ctrl.Config
+	if conf.AlmaAPIKey == "" {
+		errs = append(errs, fmt.Errorf("alma_api_key: missing"))
+	}
+	if len(conf.ILNs) == 0 {
+		errs = append(errs, fmt.Errorf("iln_to_track: missing"))
+	}
+	if conf.MappingFilePath == "" {
+		errs = append(errs, fmt.Errorf("alma-rcr_file_path: missing"))
+	}
+	if conf.AlmaBibURL != "" && !strings.Contains(conf.AlmaBibURL, "{mms}") {
+		errs = append(errs, fmt.Errorf("alma_bib_url: %q has no {mms} placeholder", conf.AlmaBibURL))
+	}
+	for _, rcr := range append(slices.Clone(conf.IgnoredSudocRCR), conf.MonolithicRCR...) {
+		if !rcrPattern.MatchString(rcr) {
+			errs = append(errs, fmt.Errorf("invalid RCR %q", rcr))
+		}
+	}
+	return append(errs, ctrl.ValidateMappings()...)
+}
+
+// ValidateMappings checks the alma-rcr mapping: RCR format, empty codes,
+// duplicated lines with overlapping validity periods, libraries with several
+// names and ILNs which are not tracked.
+func (ctrl *Controller) ValidateMappings() []error {
+	var errs []error
+	seen := make(map[[3]string][]MappingRow)
+	names := make(map[string]string)
+	for _, row := range ctrl.MappingRows() {
+		if row.AlmaCode == "" {
+			errs = append(errs, fmt.Errorf("mapping line %d: empty Alma library code", row.Line))
+		}
+		if !rcrPattern.MatchString(row.RCR) {
+			errs = append(errs, fmt.Errorf("mapping line %d: invalid RCR %q", row.Line, row.RCR))
+		}
+		if len(ctrl.Config.ILNs) > 0 && !slices.Contains(ctrl.Config.ILNs, row.ILN) {
+			errs = append(errs, fmt.Errorf("mapping line %d: ILN %q is not in iln_to_track", row.Line, row.ILN))
+		}
+		key := [3]string{row.AlmaCode, row.AlmaLocation, row.RCR}
+		if i := slices.IndexFunc(seen[key], row.overlaps); i >= 0 {
+			errs = append(errs, fmt.Errorf("mapping line %d: %s/%s already mapped line %d",
+				row.Line, strings.Trim(row.AlmaCode+"/"+row.AlmaLocation, "/"), row.RCR, seen[key][i].Line))
+		}
+		seen[key] = append(seen[key], row)
+		if row.AlmaName == "" {
+			continue
+		}
+		if name, ok := names[row.AlmaCode]; ok && name != row.AlmaName {
+			errs = append(errs, fmt.Errorf("mapping line %d: %s named %q, was %q",
+				row.Line, row.AlmaCode, row.AlmaName, name))
+		} else if !ok {
+			names[row.AlmaCode] = row.AlmaName
+		}
+	}
+	return errs
+}
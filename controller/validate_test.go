@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewOfflineController(t *testing.T) {
+	dir := t.TempDir()
+	mapping := `"BU Sciences",BIB_1,100000001,AA
+"BU Droit",BIB_2,200000001,AA
+"BU Droit",BIB_2,200000001,AA
+"BU Lettres",BIB_2,30000000X,BB
+"BU Santé",BIB_3,12345,AA
+`
+	conf := `{"alma-rcr_file_path": "alma-rcr.csv", "iln_to_track": ["AA"], "alma_bib_url": "https://alma.example.org/"}`
+	if err := os.WriteFile(filepath.Join(dir, "alma-rcr.csv"), []byte(mapping), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The mapping file is found relative to the configuration file.
+	ctrl, err := NewOfflineController(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := ctrl.MappingRows(); len(rows) != 5 || rows[3].Line != 4 || rows[3].RCR != "30000000X" {
+		t.Errorf("unexpected mapping rows %+v", rows)
+	}
+	if ctrl.Config.ExceptionsMode != ExceptionsSuppress {
+		t.Errorf("want default exceptions mode %q, got %q", ExceptionsSuppress, ctrl.Config.ExceptionsMode)
+	}
+
+	// alma_api_key, alma_bib_url, duplicate, name, ILN, RCR.
+	if errs := ctrl.ValidateConfig(); len(errs) != 6 {
+		t.Errorf("want 6 problems, got %d: %v", len(errs), errs)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "alma-rcr.csv"), []byte("BIB_1,100000001\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewOfflineController(filepath.Join(dir, "config.json")); err == nil {
+		t.Error("want error on a mapping line with missing columns")
+	}
+}
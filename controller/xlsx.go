@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Minimal Office Open XML spreadsheet writer: inline strings, one bold style
+// for headers, one style for hyperlinks, frozen header row and autofilter.
+
+// xlsxCell is a single cell of a worksheet.
+type xlsxCell struct {
+	value  string
+	number bool
+	link   string
+}
+
+// xlsxSheet is a worksheet whose first row is a header row.
+type xlsxSheet struct {
+	name string
+	rows [][]xlsxCell
+}
+
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleHeader
+	xlsxStyleLink
+)
+
+const (
+	xlsxMinWidth = 8
+	xlsxMaxWidth = 50
+)
+
+// xlsxSheetNameReplacer removes the characters forbidden in sheet names.
+var xlsxSheetNameReplacer = strings.NewReplacer(
+	"[", "(", "]", ")", ":", "-", "*", "-", "?", "", "/", "-", "\\", "-")
+
+// xlsxSheetName returns a valid sheet name (at most 31 characters, no
+// forbidden characters), different from the ones already used.
+func xlsxSheetName(name string, used map[string]bool) string {
+	name = strings.TrimSpace(xlsxSheetNameReplacer.Replace(name))
+	name = strings.Trim(name, "'")
+	if name == "" {
+		name = "Feuille"
+	}
+	candidate := truncateRunes(name, 31)
+	for i := 2; used[strings.ToLower(candidate)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		candidate = strings.TrimSpace(truncateRunes(name, 31-len(suffix))) + suffix
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// xlsxColumn returns the column name of the zero-based index i: A, B, ..., AA...
+func xlsxColumn(i int) string {
+	var name []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = append([]byte{byte('A' + (i-1)%26)}, name...)
+	}
+	return string(name)
+}
+
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// xlsxPart is a file of the zip archive.
+type xlsxPart struct {
+	name    string
+	content string
+}
+
+// writeXLSX writes a workbook made of the given sheets.
+func writeXLSX(w io.Writer, sheets []xlsxSheet) error {
+	z := zip.NewWriter(w)
+	files := []xlsxPart{
+		{"[Content_Types].xml", xlsxContentTypes(len(sheets))},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook(sheets)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels(len(sheets))},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for i, sheet := range sheets {
+		content, rels := xlsxWorksheet(sheet)
+		files = append(files, xlsxPart{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), content})
+		if rels != "" {
+			files = append(files, xlsxPart{fmt.Sprintf("xl/worksheets/_rels/sheet%d.xml.rels", i+1), rels})
+		}
+	}
+
+	for _, file := range files {
+		f, err := z.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, file.content); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+const xlsxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const xlsxRootRels = xlsxHeader +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxStyles = xlsxHeader +
+	`<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="3">` +
+	`<font><sz val="11"/><name val="Calibri"/></font>` +
+	`<font><b/><sz val="11"/><name val="Calibri"/></font>` +
+	`<font><u/><sz val="11"/><color rgb="FF0563C1"/><name val="Calibri"/></font>` +
+	`</fonts>` +
+	`<fills count="3"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFD9E1F2"/><bgColor indexed="64"/></patternFill></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1"/>` +
+	`<xf numFmtId="0" fontId="2" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+func xlsxContentTypes(n int) string {
+	var sb strings.Builder
+	sb.WriteString(xlsxHeader)
+	sb.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	sb.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	sb.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	sb.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	sb.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&sb, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	sb.WriteString(`</Types>`)
+	return sb.String()
+}
+
+func xlsxWorkbook(sheets []xlsxSheet) string {
+	var sb strings.Builder
+	sb.WriteString(xlsxHeader)
+	sb.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	sb.WriteString(`<sheets>`)
+	for i, sheet := range sheets {
+		fmt.Fprintf(&sb, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(sheet.name), i+1, i+1)
+	}
+	sb.WriteString(`</sheets>`)
+	var names strings.Builder
+	for i, sheet := range sheets {
+		if len(sheet.rows) == 0 {
+			continue
+		}
+		fmt.Fprintf(&names, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">'%s'!%s</definedName>`,
+			i, xmlEscape(strings.ReplaceAll(sheet.name, "'", "''")), xlsxRange(sheet, true))
+	}
+	if names.Len() > 0 {
+		fmt.Fprintf(&sb, `<definedNames>%s</definedNames>`, names.String())
+	}
+	sb.WriteString(`</workbook>`)
+	return sb.String()
+}
+
+func xlsxWorkbookRels(n int) string {
+	var sb strings.Builder
+	sb.WriteString(xlsxHeader)
+	sb.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&sb, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&sb, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, n+1)
+	sb.WriteString(`</Relationships>`)
+	return sb.String()
+}
+
+// xlsxRange returns the range covered by the sheet, eg A1:E12, with absolute
+// references if abs is true.
+func xlsxRange(sheet xlsxSheet, abs bool) string {
+	width := 1
+	for _, row := range sheet.rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	height := len(sheet.rows)
+	if height == 0 {
+		height = 1
+	}
+	if abs {
+		return fmt.Sprintf("$A$1:$%s$%d", xlsxColumn(width-1), height)
+	}
+	return fmt.Sprintf("A1:%s%d", xlsxColumn(width-1), height)
+}
+
+// xlsxWorksheet returns the XML content of the sheet and of its relationships
+// file, which is empty if the sheet has no hyperlink.
+func xlsxWorksheet(sheet xlsxSheet) (string, string) {
+	var sb, links, rels strings.Builder
+	nlinks := 0
+
+	sb.WriteString(xlsxHeader)
+	sb.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	fmt.Fprintf(&sb, `<dimension ref="%s"/>`, xlsxRange(sheet, false))
+	sb.WriteString(`<sheetViews><sheetView workbookViewId="0">`)
+	sb.WriteString(`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`)
+	sb.WriteString(`<selection pane="bottomLeft" activeCell="A2" sqref="A2"/>`)
+	sb.WriteString(`</sheetView></sheetViews>`)
+
+	if widths := xlsxWidths(sheet); len(widths) > 0 {
+		sb.WriteString(`<cols>`)
+		for i, width := range widths {
+			fmt.Fprintf(&sb, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, width)
+		}
+		sb.WriteString(`</cols>`)
+	}
+
+	sb.WriteString(`<sheetData>`)
+	for r, row := range sheet.rows {
+		fmt.Fprintf(&sb, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			ref := xlsxColumn(c) + strconv.Itoa(r+1)
+			style := xlsxStyleDefault
+			if r == 0 {
+				style = xlsxStyleHeader
+			} else if cell.link != "" {
+				style = xlsxStyleLink
+				nlinks++
+				fmt.Fprintf(&links, `<hyperlink ref="%s" r:id="rId%d"/>`, ref, nlinks)
+				fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="%s" TargetMode="External"/>`,
+					nlinks, xmlEscape(cell.link))
+			}
+			if cell.number {
+				fmt.Fprintf(&sb, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, xmlEscape(cell.value))
+			} else {
+				fmt.Fprintf(&sb, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`,
+					ref, style, xmlEscape(cell.value))
+			}
+		}
+		sb.WriteString(`</row>`)
+	}
+	sb.WriteString(`</sheetData>`)
+
+	if len(sheet.rows) > 0 {
+		fmt.Fprintf(&sb, `<autoFilter ref="%s"/>`, xlsxRange(sheet, false))
+	}
+	if nlinks > 0 {
+		fmt.Fprintf(&sb, `<hyperlinks>%s</hyperlinks>`, links.String())
+	}
+	sb.WriteString(`<pageMargins left="0.7" right="0.7" top="0.75" bottom="0.75" header="0.3" footer="0.3"/>`)
+	sb.WriteString(`</worksheet>`)
+
+	if nlinks == 0 {
+		return sb.String(), ""
+	}
+	return sb.String(), xlsxHeader +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		rels.String() + `</Relationships>`
+}
+
+// xlsxWidths computes the width of each column from the longest value it
+// contains.
+func xlsxWidths(sheet xlsxSheet) []int {
+	var widths []int
+	for _, row := range sheet.rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, xlsxMinWidth)
+			}
+			// Leave room for the autofilter button.
+			width := utf8.RuneCountInString(cell.value) + 3
+			if width > xlsxMaxWidth {
+				width = xlsxMaxWidth
+			}
+			if width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+	return widths
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	tests := []struct {
+		input int
+		want  string
+	}{
+		{0, "A"}, {11, "L"}, {25, "Z"}, {26, "AA"}, {27, "AB"}, {701, "ZZ"}, {702, "AAA"},
+	}
+	for _, test := range tests {
+		if got := xlsxColumn(test.input); got != test.want {
+			t.Errorf("xlsxColumn(%d): want %s, got %s", test.input, test.want, got)
+		}
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	used := make(map[string]bool)
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"BU Sciences [1/2]", "BU Sciences (1-2)"},
+		{"", "Feuille"},
+		{"Bibliothèque universitaire de médecine", "Bibliothèque universitaire de m"},
+		{"Bibliothèque universitaire de médecine et pharmacie", "Bibliothèque universitaire (2)"},
+		{"bu sciences (1-2)", "bu sciences (1-2) (2)"},
+	}
+	for _, test := range tests {
+		if got := xlsxSheetName(test.input, used); got != test.want {
+			t.Errorf("xlsxSheetName(%q): want %q, got %q", test.input, test.want, got)
+		}
+	}
+}
+
+func TestEncodeXLSX(t *testing.T) {
+	ctrl := Controller{
+		Config:   &Config{AlmaBibURL: "https://alma.example.org/bib/{mms}"},
+		Mappings: &Mappings{},
+		Output:   OutputOptions{GroupBy: GroupByRCR},
+	}
+	var buf bytes.Buffer
+	if err := ctrl.encodeXLSX(&buf, provideSummaries()); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Every part must be well-formed XML.
+		dec := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+		}
+		parts[f.Name] = string(content)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "xl/workbook.xml",
+		"xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml",
+		"xl/worksheets/sheet3.xml", "xl/worksheets/_rels/sheet2.xml.rels"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+	if _, ok := parts["xl/worksheets/sheet4.xml"]; ok {
+		t.Error("want 3 sheets, got more")
+	}
+	workbook := parts["xl/workbook.xml"]
+	for _, name := range []string{`name="Synthèse"`, `name="100000001"`, `name="200000001"`} {
+		if !strings.Contains(workbook, name) {
+			t.Errorf("workbook: missing sheet %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/worksheets/_rels/sheet2.xml.rels"], "https://www.sudoc.fr/123456789") {
+		t.Error("missing link to the SUDOC record")
+	}
+	if !strings.Contains(parts["xl/worksheets/_rels/sheet3.xml.rels"], "https://alma.example.org/bib/mms_1") {
+		t.Error("missing link to the Alma record")
+	}
+	if !strings.Contains(parts["xl/worksheets/sheet2.xml"], `state="frozen"`) ||
+		!strings.Contains(parts["xl/worksheets/sheet2.xml"], `<autoFilter ref="A1:M2"/>`) {
+		t.Error("want frozen header and autofilter")
+	}
+}
+
+func TestEncodeXLSXUnmappedRCR(t *testing.T) {
+	ctrl := Controller{
+		Config:   &Config{},
+		Mappings: &Mappings{alma2str: map[string]string{"BIB_1": "Bibliothèque 1"}},
+		Output:   OutputOptions{GroupBy: GroupByAlma},
+	}
+	var buf bytes.Buffer
+	if err := ctrl.encodeXLSX(&buf, provideSummaries()); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range z.File {
+		if f.Name != "xl/workbook.xml" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		workbook, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The anomaly of the unmapped RCR has its own sheet.
+		if !strings.Contains(string(workbook), `name="Sans bibliothèque Alma"`) {
+			t.Errorf("missing sheet of the unmapped RCR in %s", workbook)
+		}
+		return
+	}
+	t.Error("missing workbook")
+}
@@ -1,9 +1,11 @@
 package entities
 
 import (
+	"casl/marc"
 	"fmt"
 	"slices"
 	"strings"
+	"time"
 )
 
 type BibRecord struct {
@@ -11,16 +13,55 @@ type BibRecord struct {
 	MMS            string
 	SudocLocations []*SudocLocation
 	AlmaLocations  []*AlmaLocation
+	// FetchedAt is the time when the locations were retrieved.
+	FetchedAt time.Time
+	// Filtered are the locations discarded by Filter, which are not checked.
+	Filtered []FilteredLocation
+	// Sudoc is the SUDOC record the locations were read from, if any.
+	Sudoc *marc.Record `json:"-"`
+}
+
+// FilterReason tells why a location is not checked.
+type FilterReason string
+
+const (
+	// ReasonRCRNotFollowed: the RCR of the SUDOC location is not followed.
+	ReasonRCRNotFollowed FilterReason = "rcr_not_followed"
+	// ReasonUntrackedILN: the RCR of the SUDOC location is mapped to an Alma
+	// library, but belongs to an ILN which is not tracked.
+	ReasonUntrackedILN FilterReason = "untracked_iln"
+	// ReasonLibraryNotFollowed: the Alma library is not in the mappings.
+	ReasonLibraryNotFollowed FilterReason = "library_not_followed"
+	// ReasonNoDiscovery: the Alma holding is suppressed from discovery.
+	ReasonNoDiscovery FilterReason = "no_discovery"
+	// ReasonIgnoredCollection: the Alma location is an ignored collection.
+	ReasonIgnoredCollection FilterReason = "ignored_collection"
+	// ReasonNoItems: the Alma holding has no item.
+	ReasonNoItems FilterReason = "no_items"
+	// ReasonAcquisition: all the items of the Alma holding are in
+	// acquisition.
+	ReasonAcquisition FilterReason = "acquisition"
+)
+
+// FilteredLocation is a discarded location, either from SUDOC or from Alma,
+// with the reasons why it was discarded.
+type FilteredLocation struct {
+	Sudoc   *SudocLocation
+	Alma    *AlmaLocation
+	Reasons []FilterReason
 }
 
 type SudocLocation struct {
 	ILN         string
 	RCR         string
+	EPN         string
 	Name        string
 	Sublocation string
+	CallNumber  string
 }
 
 type AlmaLocation struct {
+	MMS           string
 	Library_name  string
 	Library_code  string
 	Location_name string
@@ -50,13 +91,14 @@ func (r BibRecord) String() string {
 }
 
 func (s SudocLocation) String() string {
-	return fmt.Sprintf("ILN: %s\nRCR: %s\nNAME: %s\nSUBLOCATION: %s\n",
-		s.ILN, s.RCR, s.Name, s.Sublocation)
+	return fmt.Sprintf("ILN: %s\nRCR: %s\nEPN: %s\nNAME: %s\nSUBLOCATION: %s\nCALL NUMBER: %s\n",
+		s.ILN, s.RCR, s.EPN, s.Name, s.Sublocation, s.CallNumber)
 }
 
 func (a AlmaLocation) String() string {
 	var sb strings.Builder
 	fmt.Fprintln(&sb, "*********************************")
+	fmt.Fprintf(&sb, "MMS: %s\n", a.MMS)
 	fmt.Fprintf(&sb, "Library: %s (%s)\n", a.Library_name, a.Library_code)
 	fmt.Fprintf(&sb, "Location: %s (%s)\n", a.Location_name, a.Location_code)
 	fmt.Fprintf(&sb, "Call number: %s\n", a.Call_number)
@@ -69,16 +111,75 @@ func (a AlmaLocation) String() string {
 	return sb.String()
 }
 
-func (a *AlmaLocation) IsValid(ignored_locations []string) bool {
-	if a.NoDiscovery || a.Items == nil || len(a.Items) == 0 || slices.Contains(ignored_locations, a.Location_code) {
-		return false
+// IsValid tells if an Alma location must be checked, and if not, why.
+func (a *AlmaLocation) IsValid(ignored_locations []string) (bool, []FilterReason) {
+	var reasons []FilterReason
+	if a.NoDiscovery {
+		reasons = append(reasons, ReasonNoDiscovery)
 	}
-	for _, item := range a.Items {
+	if slices.Contains(ignored_locations, a.Location_code) {
+		reasons = append(reasons, ReasonIgnoredCollection)
+	}
+	if len(a.Items) == 0 {
+		reasons = append(reasons, ReasonNoItems)
+	} else if !slices.ContainsFunc(a.Items, func(item *AlmaItem) bool {
 		// TODO: use a configuration instead of "ACQ" to be able to add status to be
 		// ignored
-		if item.Process_code != "ACQ" {
-			return true
+		return item.Process_code != "ACQ"
+	}) {
+		reasons = append(reasons, ReasonAcquisition)
+	}
+	return len(reasons) == 0, reasons
+}
+
+// IsFollowed tells if a SUDOC location belongs to one of the given RCRs, and
+// if not, why.
+func (s *SudocLocation) IsFollowed(rcrs []string) (bool, []FilterReason) {
+	if !slices.Contains(rcrs, s.RCR) {
+		return false, []FilterReason{ReasonRCRNotFollowed}
+	}
+	return true, nil
+}
+
+// Filter keeps the locations of the record which must be checked: SUDOC
+// locations of the given RCRs, valid Alma locations of the given libraries.
+// The other ones are moved to Filtered.
+func (r *BibRecord) Filter(rcrs, lib_codes, ignored_locations []string) {
+	var sudoc []*SudocLocation
+	for _, loc := range r.SudocLocations {
+		if ok, reasons := loc.IsFollowed(rcrs); ok {
+			sudoc = append(sudoc, loc)
+		} else {
+			r.Filtered = append(r.Filtered, FilteredLocation{Sudoc: loc, Reasons: reasons})
+		}
+	}
+	r.SudocLocations = sudoc
+
+	var alma []*AlmaLocation
+	for _, loc := range r.AlmaLocations {
+		var reasons []FilterReason
+		if !slices.Contains(lib_codes, loc.Library_code) {
+			reasons = append(reasons, ReasonLibraryNotFollowed)
+		}
+		if ok, invalid := loc.IsValid(ignored_locations); !ok {
+			reasons = append(reasons, invalid...)
+		}
+		if len(reasons) == 0 {
+			alma = append(alma, loc)
+		} else {
+			r.Filtered = append(r.Filtered, FilteredLocation{Alma: loc, Reasons: reasons})
+		}
+	}
+	r.AlmaLocations = alma
+}
+
+// Reasons returns the reasons why a location of the record was filtered out,
+// or nil if it was kept.
+func (r *BibRecord) Reasons(location any) []FilterReason {
+	for _, f := range r.Filtered {
+		if (f.Sudoc != nil && f.Sudoc == location) || (f.Alma != nil && f.Alma == location) {
+			return f.Reasons
 		}
 	}
-	return false
+	return nil
 }
@@ -1,6 +1,9 @@
 package entities
 
-import "testing"
+import (
+	"slices"
+	"testing"
+)
 
 func TestValid(t *testing.T) {
 	validLocations := provideValidAlmaLocations()
@@ -8,14 +11,14 @@ func TestValid(t *testing.T) {
 
 	for _, l := range validLocations {
 		t.Run(l.Library_name, func(t *testing.T) {
-			if !l.IsValid([]string{"PILON", "PERDU"}) {
+			if ok, reasons := l.IsValid([]string{"PILON", "PERDU"}); !ok || reasons != nil {
 				t.Errorf("got false; want true")
 			}
 		})
 	}
 	for _, l := range invalidLocations {
 		t.Run(l.Library_name, func(t *testing.T) {
-			if l.IsValid([]string{"PILON", "PERDU"}) {
+			if ok, _ := l.IsValid([]string{"PILON", "PERDU"}); ok {
 				t.Errorf("got true; want false")
 			}
 		})
@@ -65,3 +68,49 @@ func provideValidAlmaLocations() []AlmaLocation {
 
 	return res
 }
+
+func TestIsValidReasons(t *testing.T) {
+	acq := AlmaItem{Process_code: "ACQ"}
+	tests := []struct {
+		location AlmaLocation
+		want     []FilterReason
+	}{
+		{AlmaLocation{NoDiscovery: true, Location_code: "PILON"}, []FilterReason{ReasonNoDiscovery, ReasonIgnoredCollection, ReasonNoItems}},
+		{AlmaLocation{Items: []*AlmaItem{&acq}}, []FilterReason{ReasonAcquisition}},
+	}
+	for _, test := range tests {
+		if _, got := test.location.IsValid([]string{"PILON"}); !slices.Equal(got, test.want) {
+			t.Errorf("%+v: want %v, got %v", test.location, test.want, got)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	item := AlmaItem{Process_code: "LOAN"}
+	record := BibRecord{
+		SudocLocations: []*SudocLocation{{RCR: "100000001"}, {RCR: "900000001"}},
+		AlmaLocations: []*AlmaLocation{
+			{Library_code: "BIB_1", Items: []*AlmaItem{&item}},
+			{Library_code: "BIB_9", Items: []*AlmaItem{&item}},
+			{Library_code: "BIB_1", NoDiscovery: true, Items: []*AlmaItem{&item}},
+		},
+	}
+	ignored := record.AlmaLocations[2]
+	record.Filter([]string{"100000001"}, []string{"BIB_1"}, nil)
+
+	if len(record.SudocLocations) != 1 || len(record.AlmaLocations) != 1 || len(record.Filtered) != 3 {
+		t.Fatalf("unexpected filtered record %+v", record)
+	}
+	if got := record.Filtered[0].Reasons; !slices.Equal(got, []FilterReason{ReasonRCRNotFollowed}) {
+		t.Errorf("SUDOC: want %s, got %v", ReasonRCRNotFollowed, got)
+	}
+	if got := record.Filtered[1].Reasons; !slices.Equal(got, []FilterReason{ReasonLibraryNotFollowed}) {
+		t.Errorf("Alma: want %s, got %v", ReasonLibraryNotFollowed, got)
+	}
+	if got := record.Reasons(ignored); !slices.Equal(got, []FilterReason{ReasonNoDiscovery}) {
+		t.Errorf("Alma: want %s, got %v", ReasonNoDiscovery, got)
+	}
+	if got := record.Reasons(record.AlmaLocations[0]); got != nil {
+		t.Errorf("kept location: want no reason, got %v", got)
+	}
+}
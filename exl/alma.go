@@ -5,7 +5,9 @@ package exl
 
 import (
 	"casl/entities"
+	"casl/marc"
 	"casl/requests"
+	"context"
 	"errors"
 	"fmt"
 	"slices"
@@ -22,6 +24,7 @@ type AlmaClient struct {
 type stats struct {
 	bibs_req  int
 	items_req int
+	conf_req  int
 }
 
 const almawsURL = "https://api-eu.hosted.exlibrisgroup.com/almaws/v1/"
@@ -29,6 +32,8 @@ const almawsURL = "https://api-eu.hosted.exlibrisgroup.com/almaws/v1/"
 const (
 	bibs_t int = iota
 	items_t
+	bib_t
+	conf_t
 )
 
 // TODO: decode errors from EXL API
@@ -60,14 +65,22 @@ func NewAlmaClient(apiKey, baseURL string, fetcher requests.Fetcher) (*AlmaClien
 // from the items API. Only the locations regarding the libraries of
 // interest, given as a second argument, are provided.
 func (a *AlmaClient) GetFilteredLocations(ppn string, lib_codes []string, ignored_locations []string) ([]*entities.AlmaLocation, error) {
-	locations, err := a.GetLocations(ppn)
+	return a.GetFilteredLocationsContext(context.Background(), ppn, lib_codes, ignored_locations)
+}
+
+// GetFilteredLocationsContext is GetFilteredLocations with a context.
+func (a *AlmaClient) GetFilteredLocationsContext(ctx context.Context, ppn string, lib_codes []string, ignored_locations []string) ([]*entities.AlmaLocation, error) {
+	locations, err := a.GetLocationsContext(ctx, ppn)
 	var filtered []*entities.AlmaLocation
 	if err != nil {
 		return filtered, err
 	}
 
 	for _, location := range locations {
-		if slices.Contains(lib_codes, location.Library_code) && location.IsValid(ignored_locations) {
+		if !slices.Contains(lib_codes, location.Library_code) {
+			continue
+		}
+		if ok, _ := location.IsValid(ignored_locations); ok {
 			filtered = append(filtered, location)
 		}
 	}
@@ -77,17 +90,25 @@ func (a *AlmaClient) GetFilteredLocations(ppn string, lib_codes []string, ignore
 // GetLocations gets all the Alma locations of a given PPN, from the item  API,
 // filled with data from client's mappings.
 func (a *AlmaClient) GetLocations(ppn string) ([]*entities.AlmaLocation, error) {
+	return a.GetLocationsContext(context.Background(), ppn)
+}
+
+// GetLocationsContext is GetLocations with a context.
+func (a *AlmaClient) GetLocationsContext(ctx context.Context, ppn string) ([]*entities.AlmaLocation, error) {
 	var res []*entities.AlmaLocation
-	mms, err := a.getMMSfromPPN(ppn)
+	mms, err := a.getMMSfromPPN(ctx, ppn)
 	if err != nil {
 		return res, err
 	}
 	if len(mms) == 0 {
-		return res, fmt.Errorf("GetAlmaLocation: PPN %s not found", ppn)
+		return res, &NotFoundError{id: ppn, errorMessage: "GetAlmaLocation: PPN not found"}
 	} else if len(mms) > 1 {
 		return res, fmt.Errorf("GetAlmaLocation: PPN %s found in %v", ppn, mms)
 	}
-	items, err := a.getItems(mms[0])
+	items, err := a.getItems(ctx, mms[0])
+	if err != nil {
+		return res, err
+	}
 	items_by_mms := make(map[string][]Item)
 	for _, item := range items {
 		items_by_mms[item.Holding_data.MMS] = append(items_by_mms[item.Holding_data.MMS], item)
@@ -96,6 +117,7 @@ func (a *AlmaClient) GetLocations(ppn string) ([]*entities.AlmaLocation, error)
 	for _, v := range items_by_mms {
 		var location entities.AlmaLocation
 		var items []*entities.AlmaItem
+		location.MMS = mms[0]
 		for _, item := range v {
 			var almaItem entities.AlmaItem
 			almaItem.Process_code = item.Details.Process.Code
@@ -116,8 +138,51 @@ func (a *AlmaClient) GetLocations(ppn string) ([]*entities.AlmaLocation, error)
 	return res, nil
 }
 
+// GetBib gets the full bibliographic record of a given MMS, counted as a bibs
+// request.
+func (a *AlmaClient) GetBib(mms string) (*marc.Record, error) {
+	return a.GetBibContext(context.Background(), mms)
+}
+
+// GetBibContext is GetBib with a context.
+func (a *AlmaClient) GetBibContext(ctx context.Context, mms string) (*marc.Record, error) {
+	a.stats.bibs_req += 1
+	data, err := requests.FetchContext(ctx, a.fetcher, a.buildURL(bib_t, mms))
+	if err != nil {
+		return nil, err
+	}
+	bib, err := decodeBibXML(data)
+	if err != nil {
+		return nil, errors.New("alma: GetBib: unable to decode XML data")
+	}
+	if bib.Record == nil {
+		return nil, fmt.Errorf("alma: GetBib: no record for MMS %s", mms)
+	}
+	return bib.Record, nil
+}
+
+// GetLibraries gets the libraries of the institution from the configuration
+// API.
+func (a *AlmaClient) GetLibraries() ([]Library, error) {
+	return a.GetLibrariesContext(context.Background())
+}
+
+// GetLibrariesContext is GetLibraries with a context.
+func (a *AlmaClient) GetLibrariesContext(ctx context.Context) ([]Library, error) {
+	a.stats.conf_req += 1
+	data, err := requests.FetchContext(ctx, a.fetcher, a.buildURL(conf_t, "libraries"))
+	if err != nil {
+		return nil, err
+	}
+	libraries, err := decodeLibrariesXML(data)
+	if err != nil {
+		return nil, errors.New("alma: GetLibraries: unable to decode XML data")
+	}
+	return libraries, nil
+}
+
 // Stats returns numbers of requests made by the client to the service named
-// by the argument ("bibs", "items", "total").
+// by the argument ("bibs", "items", "conf", "total").
 // TODO: provide a better way to select the stat than by string
 func (a *AlmaClient) Stats(t string) int {
 	switch t {
@@ -125,8 +190,10 @@ func (a *AlmaClient) Stats(t string) int {
 		return a.stats.bibs_req
 	case "items":
 		return a.stats.items_req
+	case "conf":
+		return a.stats.conf_req
 	case "total":
-		return a.stats.bibs_req + a.stats.items_req
+		return a.stats.bibs_req + a.stats.items_req + a.stats.conf_req
 	default:
 		return a.Stats("total")
 	}
@@ -135,9 +202,9 @@ func (a *AlmaClient) Stats(t string) int {
 // getItems returns a list of all the items linked to the bibliographic record
 // given as a parameter via its MMS. Alma API limits the number of retrieved
 // items to 100.
-func (a *AlmaClient) getItems(mms string) ([]Item, error) {
+func (a *AlmaClient) getItems(ctx context.Context, mms string) ([]Item, error) {
 	a.stats.items_req += 1
-	data, err := a.fetcher.Fetch(a.buildURL(items_t, mms))
+	data, err := requests.FetchContext(ctx, a.fetcher, a.buildURL(items_t, mms))
 	if err != nil {
 		return nil, err
 	}
@@ -149,9 +216,9 @@ func (a *AlmaClient) getItems(mms string) ([]Item, error) {
 }
 
 // getMMSfromPPN returns a list of MMS corresponding to the given PPN.
-func (a *AlmaClient) getMMSfromPPN(ppn string) ([]string, error) {
+func (a *AlmaClient) getMMSfromPPN(ctx context.Context, ppn string) ([]string, error) {
 	a.stats.bibs_req += 1
-	data, err := a.fetcher.Fetch(a.buildURL(bibs_t, "(PPN)"+ppn))
+	data, err := requests.FetchContext(ctx, a.fetcher, a.buildURL(bibs_t, "(PPN)"+ppn))
 	if err != nil { // HTTP errors
 		return nil, err
 	}
@@ -174,6 +241,10 @@ func (a *AlmaClient) buildURL(urlType int, id string) string {
 		return a.baseURL + "bibs?view=brief&expand=None&other_system_id=" + id + "&apikey=" + a.apiKey
 	case items_t:
 		return a.baseURL + "bibs/" + id + "/holdings/ALL/items?limit=100&apikey=" + a.apiKey
+	case bib_t:
+		return a.baseURL + "bibs/" + id + "?view=full&expand=None&apikey=" + a.apiKey
+	case conf_t:
+		return a.baseURL + "conf/" + id + "?apikey=" + a.apiKey
 	default:
 		return a.baseURL + "/" + id
 	}
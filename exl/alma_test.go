@@ -2,8 +2,11 @@ package exl
 
 import (
 	"casl/entities"
+	"casl/marc"
 	"casl/requests"
+	"context"
 	"encoding/xml"
+	"errors"
 	"os"
 	"reflect"
 	"sort"
@@ -46,6 +49,18 @@ func (m mockHttpFetcher) Fetch(url string) ([]byte, error) {
 			return nil, err
 		}
 		return data, nil
+	case almawsURL + "bibs/" + "mms_bib" + "?view=full&expand=None&apikey=key":
+		data, err := os.ReadFile("testdata/mms_bib.xml")
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	case almawsURL + "conf/libraries?apikey=key":
+		data, err := os.ReadFile("testdata/conf_libraries.xml")
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
 	case almawsURL + "bibs/" + "mms_items" + "/holdings/ALL/items?limit=100&apikey=key":
 		data, err := os.ReadFile("testdata/mms_items.xml")
 		if err != nil {
@@ -106,6 +121,7 @@ func TestNewAlmaClient(t *testing.T) {
 
 func TestGetLocations(t *testing.T) {
 	location_1 := entities.AlmaLocation{
+		MMS:           "mms_items",
 		Library_name:  "Bibliothèque 1",
 		Library_code:  "BIB_1",
 		Location_name: "Location 1",
@@ -117,6 +133,7 @@ func TestGetLocations(t *testing.T) {
 		},
 	}
 	location_2 := entities.AlmaLocation{
+		MMS:           "mms_items",
 		Library_name:  "Bibliothèque 2",
 		Library_code:  "BIB_2",
 		Location_name: "Location 2",
@@ -145,11 +162,16 @@ func TestGetLocations(t *testing.T) {
 	if err == nil || err2 == nil {
 		t.Error("want error, got ok")
 	}
+	var notFound *NotFoundError
+	if _, err := client.GetLocations("ppn_0_mms"); !errors.As(err, &notFound) {
+		t.Errorf("want NotFoundError, got %v", err)
+	}
 }
 
 func TestGetFilteredLocations(t *testing.T) {
 	locations := []*entities.AlmaLocation{
 		{
+			MMS:           "mms_items",
 			Library_name:  "Bibliothèque 1",
 			Library_code:  "BIB_1",
 			Location_name: "Location 1",
@@ -163,7 +185,7 @@ func TestGetFilteredLocations(t *testing.T) {
 	}
 
 	client, _ := NewAlmaClient("key", "", mockHttpFetcher{})
-	got, err := client.GetFilteredLocations("ppn_get_locations", []string{"BIB_1"})
+	got, err := client.GetFilteredLocations("ppn_get_locations", []string{"BIB_1"}, nil)
 	if err != nil {
 		t.Errorf("returned error %v", err)
 	}
@@ -188,7 +210,7 @@ func TestGetMMSFromPPN(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			got, err := client.getMMSfromPPN(test.input)
+			got, err := client.getMMSfromPPN(context.Background(), test.input)
 			if err != nil {
 				t.Errorf("want no error, got %v", err)
 			}
@@ -225,7 +247,7 @@ func TestGetItems(t *testing.T) {
 		},
 	}
 	client, _ := NewAlmaClient("key", "", mockHttpFetcher{})
-	got, err := client.getItems("mms_items")
+	got, err := client.getItems(context.Background(), "mms_items")
 	if err != nil {
 		t.Errorf("got %v", err)
 	}
@@ -234,13 +256,61 @@ func TestGetItems(t *testing.T) {
 	}
 }
 
+func TestGetBib(t *testing.T) {
+	client, _ := NewAlmaClient("key", "", mockHttpFetcher{})
+	record, err := client.GetBib("mms_bib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := marc.MustCompile("200$a").Value(record); got != "L'étranger" {
+		t.Errorf("want title L'étranger, got %q", got)
+	}
+	if client.Stats("bibs") != 1 {
+		t.Errorf("want 1 bibs request, got %d", client.Stats("bibs"))
+	}
+	if _, err := client.GetBib("mms_unknown"); err == nil {
+		t.Error("want an error for an empty response")
+	}
+}
+
+func TestGetLibraries(t *testing.T) {
+	client, _ := NewAlmaClient("key", "", mockHttpFetcher{})
+	got, err := client.GetLibraries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Library{{"Bibliothèque universitaire 1.1", "BIB_1"}, {"Bibliothèque universitaire 2.1", "BIB_2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if client.Stats("conf") != 1 || client.Stats("total") != 1 {
+		t.Errorf("want 1 conf request, got %d", client.Stats("conf"))
+	}
+}
+
+func TestGetLocationsContext(t *testing.T) {
+	client, _ := NewAlmaClient("key", "", mockHttpFetcher{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.GetLocationsContext(ctx, "ppn_get_locations"); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	if _, err := client.GetLocationsContext(context.Background(), "ppn_get_locations"); err != nil {
+		t.Error(err)
+	}
+	// The items of mms2 cannot be read.
+	if _, err := client.GetLocationsContext(context.Background(), "ppn_1_mms"); err == nil {
+		t.Error("want the error of the items request")
+	}
+}
+
 func TestStats(t *testing.T) {
 	client, _ := NewAlmaClient("key", "", mockHttpFetcher{})
 	bibs, items, total := getStats(client)
 	if bibs != 0 || items != 0 || total != 0 {
 		t.Errorf("want 0 0 0, got %d %d %d", bibs, items, total)
 	}
-	client.getItems("mms_1")
+	client.getItems(context.Background(), "mms_1")
 	bibs, items, total = getStats(client)
 	if bibs != 0 || items != 1 || total != 1 {
 		t.Errorf("want 0 1 1, got %d %d %d", bibs, items, total)
@@ -250,7 +320,7 @@ func TestStats(t *testing.T) {
 	if bibs != 1 || items != 2 || total != 3 {
 		t.Errorf("want 1 2 3, got %d %d %d", bibs, items, total)
 	}
-	client.GetFilteredLocations("ppn_get_locations", []string{"mms_items"})
+	client.GetFilteredLocations("ppn_get_locations", []string{"mms_items"}, nil)
 	bibs, items, total = getStats(client)
 	if bibs != 2 || items != 3 || total != 5 {
 		t.Errorf("want 2 3 5, got %d %d %d", bibs, items, total)
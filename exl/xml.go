@@ -1,6 +1,7 @@
 package exl
 
 import (
+	"casl/marc"
 	"encoding/xml"
 	"fmt"
 	"strings"
@@ -12,6 +13,13 @@ type almaBib struct {
 	MMS_id          string   `xml:"mms_id"`
 }
 
+// fullBib is a bib with its MARC record.
+type fullBib struct {
+	XMLName xml.Name     `xml:"bib"`
+	MMS_id  string       `xml:"mms_id"`
+	Record  *marc.Record `xml:"record"`
+}
+
 type bibsResult struct {
 	XMLName xml.Name  `xml:"bibs"`
 	Bibs    []almaBib `xml:"bib"`
@@ -56,6 +64,15 @@ type Status struct {
 	Number string `xml:",chardata"`
 }
 
+// confLibraries is the response of the libraries configuration API.
+type confLibraries struct {
+	XMLName   xml.Name `xml:"libraries"`
+	Libraries []struct {
+		Code string `xml:"code"`
+		Name string `xml:"name"`
+	} `xml:"library"`
+}
+
 type Items struct {
 	XMLName xml.Name `xml:"items"`
 	Items   []Item   `xml:"item"`
@@ -91,6 +108,28 @@ func decodeBibsXML(data []byte) (*bibsResult, error) {
 	return &b, nil
 }
 
+func decodeBibXML(data []byte) (*fullBib, error) {
+	var b fullBib
+	err := xml.Unmarshal(data, &b)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func decodeLibrariesXML(data []byte) ([]Library, error) {
+	var conf confLibraries
+	err := xml.Unmarshal(data, &conf)
+	if err != nil {
+		return nil, err
+	}
+	libraries := make([]Library, len(conf.Libraries))
+	for i, lib := range conf.Libraries {
+		libraries[i] = Library{Name: lib.Name, Code: lib.Code}
+	}
+	return libraries, nil
+}
+
 func DecodeItemsXML(data []byte) ([]Item, error) {
 	var items Items
 	items.Items = []Item{}
@@ -0,0 +1,442 @@
+// Package history keeps the runs of casl in a local SQLite database: the
+// configuration, the checked PPNs, the fetched locations, the anomalies and
+// the request statistics.
+package history
+
+import (
+	"casl/controller"
+	"casl/entities"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Store is a history database.
+type Store struct {
+	db *sql.DB
+}
+
+// Run is everything a run of casl saw and produced.
+type Run struct {
+	ID         int64
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Config is a JSON snapshot of the configuration.
+	Config    json.RawMessage
+	PPNs      []string
+	Records   []entities.BibRecord
+	Anomalies []controller.Summary
+	// Stats holds the number of requests made to each service.
+	Stats map[string]int
+}
+
+// RunInfo describes a run without its content.
+type RunInfo struct {
+	ID         int64
+	StartedAt  time.Time
+	FinishedAt time.Time
+	PPNs       int
+	Anomalies  int
+}
+
+// TrendPoint is the number of anomalies found by a run.
+type TrendPoint struct {
+	RunID     int64
+	StartedAt time.Time
+	Anomalies int
+}
+
+// ErrNotFound is returned when the requested run does not exist.
+var ErrNotFound = errors.New("history: run not found")
+
+// Open opens the database, creating it if needed, and brings its schema up to
+// date.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	// A single connection avoids "database is locked" errors with SQLite.
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// SaveRun stores a run and sets its ID.
+func (s *Store) SaveRun(run *Run) error {
+	stats, err := json.Marshal(run.Stats)
+	if err != nil {
+		return fmt.Errorf("SaveRun: %w", err)
+	}
+	config := run.Config
+	if config == nil {
+		config = json.RawMessage("{}")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("SaveRun: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO runs (started_at, finished_at, config, stats) VALUES (?, ?, ?, ?)`,
+		formatTime(run.StartedAt), formatTime(run.FinishedAt), string(config), string(stats))
+	if err != nil {
+		return fmt.Errorf("SaveRun: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("SaveRun: %w", err)
+	}
+
+	for i, ppn := range run.PPNs {
+		if _, err := tx.Exec(`INSERT INTO run_ppns (run_id, position, ppn) VALUES (?, ?, ?)`, id, i, ppn); err != nil {
+			return fmt.Errorf("SaveRun: %w", err)
+		}
+	}
+	for _, record := range run.Records {
+		if err := saveRecord(tx, id, record); err != nil {
+			return fmt.Errorf("SaveRun: ppn %s: %w", record.PPN, err)
+		}
+	}
+	for _, a := range run.Anomalies {
+		_, err := tx.Exec(`INSERT INTO anomalies (run_id, type, ppn, iln, rcr, mms, epn,
+			sudoc_library, alma_library, alma_library_code, alma_location_code, call_number, checked_at,
+			acknowledged, comment)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, string(a.Type), a.PPN, a.ILN, a.RCR, a.MMS, a.EPN, a.SudocLib, a.AlmaLib,
+			a.AlmaLibCode, a.AlmaLocation, a.CallNumber, formatTime(a.CheckedAt),
+			a.Acknowledged, a.Comment)
+		if err != nil {
+			return fmt.Errorf("SaveRun: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("SaveRun: %w", err)
+	}
+	run.ID = id
+	return nil
+}
+
+func saveRecord(tx *sql.Tx, runID int64, record entities.BibRecord) error {
+	res, err := tx.Exec(`INSERT INTO records (run_id, ppn, mms, fetched_at) VALUES (?, ?, ?, ?)`,
+		runID, record.PPN, record.MMS, formatTime(record.FetchedAt))
+	if err != nil {
+		return err
+	}
+	recordID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, l := range record.SudocLocations {
+		_, err := tx.Exec(`INSERT INTO sudoc_locations (record_id, iln, rcr, epn, name, sublocation, call_number)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			recordID, l.ILN, l.RCR, l.EPN, l.Name, l.Sublocation, l.CallNumber)
+		if err != nil {
+			return err
+		}
+	}
+	for _, l := range record.AlmaLocations {
+		res, err := tx.Exec(`INSERT INTO alma_locations (record_id, mms, library_code, library_name,
+			location_code, location_name, call_number, no_discovery) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			recordID, l.MMS, l.Library_code, l.Library_name, l.Location_code, l.Location_name,
+			l.Call_number, l.NoDiscovery)
+		if err != nil {
+			return err
+		}
+		locationID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for _, item := range l.Items {
+			_, err := tx.Exec(`INSERT INTO alma_items (alma_location_id, process_code, process_name, status)
+				VALUES (?, ?, ?, ?)`, locationID, item.Process_code, item.Process_name, item.Status)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Run loads a complete run.
+func (s *Store) Run(id int64) (*Run, error) {
+	run := Run{ID: id}
+	var started, finished, config, stats string
+	err := s.db.QueryRow(`SELECT started_at, finished_at, config, stats FROM runs WHERE id = ?`, id).
+		Scan(&started, &finished, &config, &stats)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Run: %w", err)
+	}
+	run.StartedAt = parseTime(started)
+	run.FinishedAt = parseTime(finished)
+	run.Config = json.RawMessage(config)
+	if err := json.Unmarshal([]byte(stats), &run.Stats); err != nil {
+		return nil, fmt.Errorf("Run: stats: %w", err)
+	}
+
+	if run.PPNs, err = s.ppns(id); err != nil {
+		return nil, fmt.Errorf("Run: %w", err)
+	}
+	if run.Records, err = s.records(id); err != nil {
+		return nil, fmt.Errorf("Run: %w", err)
+	}
+	if run.Anomalies, err = s.Anomalies(id); err != nil {
+		return nil, fmt.Errorf("Run: %w", err)
+	}
+	return &run, nil
+}
+
+// LastRunID returns the ID of the most recent run.
+func (s *Store) LastRunID() (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`SELECT id FROM runs ORDER BY id DESC LIMIT 1`).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("LastRunID: %w", err)
+	}
+	return id, nil
+}
+
+// Runs lists all the runs, oldest first.
+func (s *Store) Runs() ([]RunInfo, error) {
+	rows, err := s.db.Query(`SELECT r.id, r.started_at, r.finished_at,
+		(SELECT count(*) FROM run_ppns p WHERE p.run_id = r.id),
+		(SELECT count(*) FROM anomalies a WHERE a.run_id = r.id)
+		FROM runs r ORDER BY r.id`)
+	if err != nil {
+		return nil, fmt.Errorf("Runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []RunInfo
+	for rows.Next() {
+		var info RunInfo
+		var started, finished string
+		if err := rows.Scan(&info.ID, &started, &finished, &info.PPNs, &info.Anomalies); err != nil {
+			return nil, fmt.Errorf("Runs: %w", err)
+		}
+		info.StartedAt = parseTime(started)
+		info.FinishedAt = parseTime(finished)
+		runs = append(runs, info)
+	}
+	return runs, rows.Err()
+}
+
+// Anomalies returns the anomalies found by a run, or ErrNotFound if the run
+// does not exist.
+func (s *Store) Anomalies(runID int64) ([]controller.Summary, error) {
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM runs WHERE id = ?)`, runID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	rows, err := s.db.Query(`SELECT type, ppn, iln, rcr, mms, epn, sudoc_library, alma_library,
+		alma_library_code, alma_location_code, call_number, checked_at, acknowledged, comment
+		FROM anomalies WHERE run_id = ? ORDER BY rowid`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var anomalies []controller.Summary
+	for rows.Next() {
+		var a controller.Summary
+		var checked string
+		err := rows.Scan(&a.Type, &a.PPN, &a.ILN, &a.RCR, &a.MMS, &a.EPN, &a.SudocLib, &a.AlmaLib,
+			&a.AlmaLibCode, &a.AlmaLocation, &a.CallNumber, &checked, &a.Acknowledged, &a.Comment)
+		if err != nil {
+			return nil, err
+		}
+		a.CheckedAt = parseTime(checked)
+		anomalies = append(anomalies, a)
+	}
+	return anomalies, rows.Err()
+}
+
+// Trend returns the number of anomalies of a RCR found by each run since the
+// given date.
+func (s *Store) Trend(rcr string, since time.Time) ([]TrendPoint, error) {
+	rows, err := s.db.Query(`SELECT r.id, r.started_at,
+		(SELECT count(*) FROM anomalies a WHERE a.run_id = r.id AND a.rcr = ?)
+		FROM runs r WHERE r.started_at >= ? ORDER BY r.id`, rcr, formatTime(since))
+	if err != nil {
+		return nil, fmt.Errorf("Trend: %w", err)
+	}
+	defer rows.Close()
+
+	var points []TrendPoint
+	for rows.Next() {
+		var p TrendPoint
+		var started string
+		if err := rows.Scan(&p.RunID, &started, &p.Anomalies); err != nil {
+			return nil, fmt.Errorf("Trend: %w", err)
+		}
+		p.StartedAt = parseTime(started)
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+func (s *Store) ppns(runID int64) ([]string, error) {
+	rows, err := s.db.Query(`SELECT ppn FROM run_ppns WHERE run_id = ? ORDER BY position`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ppns []string
+	for rows.Next() {
+		var ppn string
+		if err := rows.Scan(&ppn); err != nil {
+			return nil, err
+		}
+		ppns = append(ppns, ppn)
+	}
+	return ppns, rows.Err()
+}
+
+func (s *Store) records(runID int64) ([]entities.BibRecord, error) {
+	rows, err := s.db.Query(`SELECT id, ppn, mms, fetched_at FROM records WHERE run_id = ? ORDER BY id`, runID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	var records []entities.BibRecord
+	for rows.Next() {
+		var id int64
+		var record entities.BibRecord
+		var fetched string
+		if err := rows.Scan(&id, &record.PPN, &record.MMS, &fetched); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		record.FetchedAt = parseTime(fetched)
+		ids = append(ids, id)
+		records = append(records, record)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The database has a single connection: the rows above must be closed
+	// before running the queries below.
+	for i, id := range ids {
+		var err error
+		if records[i].SudocLocations, err = s.sudocLocations(id); err != nil {
+			return nil, err
+		}
+		if records[i].AlmaLocations, err = s.almaLocations(id); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+func (s *Store) sudocLocations(recordID int64) ([]*entities.SudocLocation, error) {
+	rows, err := s.db.Query(`SELECT iln, rcr, epn, name, sublocation, call_number
+		FROM sudoc_locations WHERE record_id = ? ORDER BY rowid`, recordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var locations []*entities.SudocLocation
+	for rows.Next() {
+		var l entities.SudocLocation
+		if err := rows.Scan(&l.ILN, &l.RCR, &l.EPN, &l.Name, &l.Sublocation, &l.CallNumber); err != nil {
+			return nil, err
+		}
+		locations = append(locations, &l)
+	}
+	return locations, rows.Err()
+}
+
+func (s *Store) almaLocations(recordID int64) ([]*entities.AlmaLocation, error) {
+	rows, err := s.db.Query(`SELECT id, mms, library_code, library_name, location_code, location_name,
+		call_number, no_discovery FROM alma_locations WHERE record_id = ? ORDER BY id`, recordID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	var locations []*entities.AlmaLocation
+	for rows.Next() {
+		var id int64
+		var l entities.AlmaLocation
+		err := rows.Scan(&id, &l.MMS, &l.Library_code, &l.Library_name, &l.Location_code,
+			&l.Location_name, &l.Call_number, &l.NoDiscovery)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		locations = append(locations, &l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		items, err := s.almaItems(id)
+		if err != nil {
+			return nil, err
+		}
+		locations[i].Items = items
+	}
+	return locations, nil
+}
+
+func (s *Store) almaItems(locationID int64) ([]*entities.AlmaItem, error) {
+	rows, err := s.db.Query(`SELECT process_code, process_name, status
+		FROM alma_items WHERE alma_location_id = ? ORDER BY rowid`, locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*entities.AlmaItem
+	for rows.Next() {
+		var item entities.AlmaItem
+		if err := rows.Scan(&item.Process_code, &item.Process_name, &item.Status); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
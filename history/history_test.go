@@ -0,0 +1,129 @@
+package history
+
+import (
+	"casl/controller"
+	"casl/entities"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func provideRun(started time.Time) *Run {
+	return &Run{
+		StartedAt:  started,
+		FinishedAt: started.Add(time.Minute),
+		Config:     json.RawMessage(`{"iln_to_track":["1"]}`),
+		PPNs:       []string{"123456789", "98765432X"},
+		Records: []entities.BibRecord{
+			{
+				PPN:       "123456789",
+				FetchedAt: started,
+				SudocLocations: []*entities.SudocLocation{
+					{ILN: "1", RCR: "100000001", EPN: "EX1", Name: "UNIV-1.1", CallNumber: "823.9 WOO"},
+				},
+			},
+			{
+				PPN:       "98765432X",
+				MMS:       "mms_1",
+				FetchedAt: started,
+				AlmaLocations: []*entities.AlmaLocation{
+					{MMS: "mms_1", Library_code: "BIB_1", Library_name: "Bibliothèque 1",
+						Location_code: "LOC_1", Call_number: "CN_1",
+						Items: []*entities.AlmaItem{{Status: "Item in place"}}},
+				},
+			},
+		},
+		Anomalies: []controller.Summary{
+			{Type: controller.MissingInAlma, ILN: "1", RCR: "100000001", PPN: "123456789",
+				EPN: "EX1", SudocLib: "UNIV-1.1", CheckedAt: started},
+			{Type: controller.MissingInSudoc, ILN: "1", RCR: "100000001", PPN: "98765432X",
+				MMS: "mms_1", AlmaLibCode: "BIB_1", CheckedAt: started,
+				Acknowledged: true, Comment: "exemplaire de salle de lecture"},
+		},
+		Stats: map[string]int{"alma_bibs": 2, "sudoc_marcxml": 2},
+	}
+}
+
+func TestSaveRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "casl.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	started := time.Date(2024, 1, 31, 15, 45, 0, 0, time.UTC)
+	want := provideRun(started)
+	if err := store.SaveRun(want); err != nil {
+		t.Fatal(err)
+	}
+	if want.ID == 0 {
+		t.Fatal("SaveRun did not set the run ID")
+	}
+
+	got, err := store.Run(want.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	if _, err := store.Run(want.ID + 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+	if _, err := store.Anomalies(want.ID + 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("anomalies: want ErrNotFound, got %v", err)
+	}
+}
+
+func TestRunsAndTrend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "casl.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Date(2024, 1, 31, 15, 45, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		run := provideRun(started.AddDate(0, i, 0))
+		run.Anomalies = run.Anomalies[:i%2+1]
+		if err := store.SaveRun(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	// Reopening must not apply the migrations again.
+	store, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	runs, err := store.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 3 || runs[0].PPNs != 2 || runs[1].Anomalies != 2 {
+		t.Errorf("unexpected runs %+v", runs)
+	}
+	last, err := store.LastRunID()
+	if err != nil || last != runs[2].ID {
+		t.Errorf("want last run %d, got %d (%v)", runs[2].ID, last, err)
+	}
+
+	points, err := store.Trend("100000001", started.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TrendPoint{
+		{RunID: runs[1].ID, StartedAt: started.AddDate(0, 1, 0), Anomalies: 2},
+		{RunID: runs[2].ID, StartedAt: started.AddDate(0, 2, 0), Anomalies: 1},
+	}
+	if !reflect.DeepEqual(points, want) {
+		t.Errorf("want %v, got %v", want, points)
+	}
+}
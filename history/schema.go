@@ -0,0 +1,117 @@
+package history
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order, once. The number of applied migrations is
+// kept in the user_version pragma of the database. Never modify an existing
+// migration: append a new one.
+var migrations = []string{
+	// 1: initial schema.
+	`CREATE TABLE runs (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_at  TEXT NOT NULL,
+		finished_at TEXT NOT NULL,
+		config      TEXT NOT NULL,
+		stats       TEXT NOT NULL
+	);
+	CREATE TABLE run_ppns (
+		run_id   INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		ppn      TEXT NOT NULL,
+		PRIMARY KEY (run_id, position)
+	);
+	CREATE TABLE records (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id     INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+		ppn        TEXT NOT NULL,
+		mms        TEXT NOT NULL,
+		fetched_at TEXT NOT NULL
+	);
+	CREATE INDEX records_run ON records(run_id);
+	CREATE INDEX records_ppn ON records(ppn);
+	CREATE TABLE sudoc_locations (
+		record_id   INTEGER NOT NULL REFERENCES records(id) ON DELETE CASCADE,
+		iln         TEXT NOT NULL,
+		rcr         TEXT NOT NULL,
+		epn         TEXT NOT NULL,
+		name        TEXT NOT NULL,
+		sublocation TEXT NOT NULL,
+		call_number TEXT NOT NULL
+	);
+	CREATE INDEX sudoc_locations_record ON sudoc_locations(record_id);
+	CREATE TABLE alma_locations (
+		id            INTEGER PRIMARY KEY AUTOINCREMENT,
+		record_id     INTEGER NOT NULL REFERENCES records(id) ON DELETE CASCADE,
+		mms           TEXT NOT NULL,
+		library_code  TEXT NOT NULL,
+		library_name  TEXT NOT NULL,
+		location_code TEXT NOT NULL,
+		location_name TEXT NOT NULL,
+		call_number   TEXT NOT NULL,
+		no_discovery  INTEGER NOT NULL
+	);
+	CREATE INDEX alma_locations_record ON alma_locations(record_id);
+	CREATE TABLE alma_items (
+		alma_location_id INTEGER NOT NULL REFERENCES alma_locations(id) ON DELETE CASCADE,
+		process_code     TEXT NOT NULL,
+		process_name     TEXT NOT NULL,
+		status           TEXT NOT NULL
+	);
+	CREATE INDEX alma_items_location ON alma_items(alma_location_id);
+	CREATE TABLE anomalies (
+		run_id             INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+		type               TEXT NOT NULL,
+		ppn                TEXT NOT NULL,
+		iln                TEXT NOT NULL,
+		rcr                TEXT NOT NULL,
+		mms                TEXT NOT NULL,
+		epn                TEXT NOT NULL,
+		sudoc_library      TEXT NOT NULL,
+		alma_library       TEXT NOT NULL,
+		alma_library_code  TEXT NOT NULL,
+		alma_location_code TEXT NOT NULL,
+		call_number        TEXT NOT NULL,
+		checked_at         TEXT NOT NULL
+	);
+	CREATE INDEX anomalies_run ON anomalies(run_id);
+	CREATE INDEX anomalies_rcr ON anomalies(rcr);
+	CREATE INDEX anomalies_ppn ON anomalies(ppn);`,
+	// 2: anomalies matching an exception.
+	`ALTER TABLE anomalies ADD COLUMN acknowledged INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE anomalies ADD COLUMN comment TEXT NOT NULL DEFAULT '';`,
+}
+
+// migrate brings the database schema up to date.
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("migrate: database schema version %d is newer than supported version %d",
+			version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migrate: migration %d: %w", i+1, err)
+		}
+		// PRAGMA does not accept bound parameters.
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migrate: migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migrate: migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
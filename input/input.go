@@ -0,0 +1,138 @@
+// Package input reads the lists of PPNs to check.
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// PPNPattern matches a valid PPN: 8 digits and a check digit or X.
+var PPNPattern = regexp.MustCompile(`^[0-9]{8}[0-9xX]$`)
+
+// Result is the content of a list of PPNs.
+type Result struct {
+	PPNs []string
+	// Invalid are the values which are not PPNs.
+	Invalid []string
+}
+
+// Read reads a list of PPNs. If column is empty, the list has one PPN per
+// line. Otherwise it is a CSV or TSV file with a header, and column is the
+// name or the position (from 1) of the column containing the PPNs. The
+// encoding (UTF-8, with or without BOM, or Latin-1) is detected.
+func Read(r io.Reader, column string) (Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Result{}, fmt.Errorf("Read: %w", err)
+	}
+	data = ToUTF8(data)
+	if column == "" {
+		return readLines(data)
+	}
+	return readColumn(data, column)
+}
+
+// ToUTF8 converts the data to UTF-8: the BOM is removed, and data which is
+// not valid UTF-8 is taken as Latin-1.
+func ToUTF8(data []byte) []byte {
+	if bytes.HasPrefix(data, []byte("\ufeff")) {
+		return data[3:]
+	}
+	if utf8.Valid(data) {
+		return data
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(data) * 2)
+	for _, b := range data {
+		buf.WriteRune(rune(b))
+	}
+	return buf.Bytes()
+}
+
+func readLines(data []byte) (Result, error) {
+	var res Result
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		res.add(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return res, fmt.Errorf("Read: %w", err)
+	}
+	return res, nil
+}
+
+func readColumn(data []byte, column string) (Result, error) {
+	var res Result
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = delimiter(data)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	header, err := r.Read()
+	if err == io.EOF {
+		return res, nil
+	}
+	if err != nil {
+		return res, fmt.Errorf("Read: %w", err)
+	}
+
+	index := -1
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			index = i
+			break
+		}
+	}
+	if n, err := strconv.Atoi(column); index < 0 && err == nil && n >= 1 && n <= len(header) {
+		index = n - 1
+	}
+	if index < 0 {
+		return res, fmt.Errorf("Read: no column %q in header %v", column, header)
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("Read: %w", err)
+		}
+		if index < len(record) {
+			res.add(record[index])
+		}
+	}
+	return res, nil
+}
+
+// delimiter guesses the field delimiter from the header: tab, semicolon or
+// comma.
+func delimiter(data []byte) rune {
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	switch {
+	case bytes.Contains(header, []byte("\t")):
+		return '\t'
+	case bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")):
+		return ';'
+	default:
+		return ','
+	}
+}
+
+func (res *Result) add(value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if PPNPattern.MatchString(value) {
+		res.PPNs = append(res.PPNs, value)
+	} else {
+		res.Invalid = append(res.Invalid, value)
+	}
+}
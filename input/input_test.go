@@ -0,0 +1,48 @@
+package input
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		column  string
+		ppns    []string
+		invalid []string
+	}{
+		{"lines", "123456789\n12345678X\r\n\nabc\n", "", []string{"123456789", "12345678X"}, []string{"abc"}},
+		{"bom", "\ufeff123456789\n", "", []string{"123456789"}, nil},
+		{"csv by name", "Titre,PPN\n\"Un, deux\",123456789\nTrois,\n", "ppn", []string{"123456789"}, nil},
+		{"tsv by position", "Titre\tPPN\nUn\t123456789\nDeux\t1234\n", "2", []string{"123456789"}, []string{"1234"}},
+		{"semicolon", "titre;ppn\nUn;123456789\n", "ppn", []string{"123456789"}, nil},
+		{"latin-1", "titre;ppn\n\xe9t\xe9;123456789\n", "ppn", []string{"123456789"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := Read(strings.NewReader(test.input), test.column)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(res.PPNs, test.ppns) || !slices.Equal(res.Invalid, test.invalid) {
+				t.Errorf("want %v %v, got %v %v", test.ppns, test.invalid, res.PPNs, res.Invalid)
+			}
+		})
+	}
+
+	if _, err := Read(strings.NewReader("titre,isbn\n"), "ppn"); err == nil {
+		t.Error("want error on a missing column")
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	if got := string(ToUTF8([]byte("\xe9t\xe9"))); got != "été" {
+		t.Errorf("Latin-1: want été, got %q", got)
+	}
+	if got := string(ToUTF8([]byte("\ufeffété"))); got != "été" {
+		t.Errorf("BOM: want été, got %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"casl/marc"
+)
+
+// ppnPrefixes are the prefixes of 035$a values holding a PPN.
+var ppnPrefixes = []string{"(PPN)", "(ABES)", "(SUDOC)"}
+
+// ReadMARC reads the PPNs of the records of a MARC file, either ISO 2709 or
+// MARCXML (a collection or a single record). Records without PPN are counted
+// as invalid, identified by their position, as well as malformed MARCXML
+// records. Records are read one at a time.
+func ReadMARC(r io.Reader) (Result, error) {
+	var res Result
+	br := bufio.NewReader(r)
+	var read func() (*marc.Record, error)
+	if isXML(br) {
+		read = marc.NewXMLReader(br).Read
+	} else {
+		read = marc.NewISO2709Reader(br).Read
+	}
+
+	for i := 1; ; i++ {
+		record, err := read()
+		if err == io.EOF {
+			return res, nil
+		}
+		var recordErr *marc.RecordError
+		if errors.As(err, &recordErr) {
+			res.Invalid = append(res.Invalid, recordErr.Error())
+			continue
+		}
+		if err != nil {
+			return res, fmt.Errorf("ReadMARC: %w", err)
+		}
+		if ppn := PPN(record); ppn != "" {
+			res.PPNs = append(res.PPNs, ppn)
+		} else {
+			res.Invalid = append(res.Invalid, fmt.Sprintf("record %d without PPN", i))
+		}
+	}
+}
+
+// isXML tells if the data starts like an XML document.
+func isXML(br *bufio.Reader) bool {
+	data, _ := br.Peek(512)
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '<'
+}
+
+// PPN returns the PPN of a record: the 001 field of a SUDOC record, or a 035$a
+// value prefixed by (PPN), (ABES) or (SUDOC). It returns an empty string if the
+// record has no PPN.
+func PPN(record *marc.Record) string {
+	for _, field := range record.GetField("001") {
+		for _, value := range field.GetValue("") {
+			value = strings.TrimPrefix(strings.TrimSpace(value), "PPN")
+			if PPNPattern.MatchString(value) {
+				return value
+			}
+		}
+	}
+	for _, field := range record.GetField("035") {
+		for _, value := range field.GetValue("a") {
+			value = strings.TrimSpace(value)
+			for _, prefix := range ppnPrefixes {
+				if len(value) > len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+					if ppn := strings.TrimSpace(value[len(prefix):]); PPNPattern.MatchString(ppn) {
+						return ppn
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package input
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"casl/marc"
+)
+
+func TestPPN(t *testing.T) {
+	tests := []struct {
+		record marc.Record
+		want   string
+	}{
+		{marc.Record{Controlfields: []marc.Controlfield{{Tag: "001", Value: "123456789"}}}, "123456789"},
+		{marc.Record{Controlfields: []marc.Controlfield{{Tag: "001", Value: "991234567890123"}},
+			Datafields: []marc.Datafield{
+				{Tag: "035", Subfields: []marc.Subfield{{Code: "a", Value: "(OCoLC)123456789"}}},
+				{Tag: "035", Subfields: []marc.Subfield{{Code: "a", Value: "(PPN)12345678X"}}},
+			}}, "12345678X"},
+		{marc.Record{Controlfields: []marc.Controlfield{{Tag: "001", Value: "991234567890123"}}}, ""},
+	}
+	for _, test := range tests {
+		if got := PPN(&test.record); got != test.want {
+			t.Errorf("PPN(%+v): want %q, got %q", test.record, test.want, got)
+		}
+	}
+}
+
+func TestReadMARC(t *testing.T) {
+	xml := `
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+<record><controlfield tag="001">123456789</controlfield></record>
+<record><controlfield tag="001">991234567890123</controlfield></record>
+</collection>`
+	res, err := ReadMARC(strings.NewReader(xml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.PPNs, []string{"123456789"}) || len(res.Invalid) != 1 {
+		t.Errorf("MARCXML: unexpected result %+v", res)
+	}
+
+	iso := "00048     22000371  450 001001000000\x1e12345678X\x1e\x1d"
+	res, err = ReadMARC(strings.NewReader(iso))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.PPNs, []string{"12345678X"}) {
+		t.Errorf("ISO 2709: unexpected result %+v", res)
+	}
+}
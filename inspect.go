@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"casl/controller"
+	"casl/input"
+	"casl/requests"
+)
+
+var inspectCmd = &command{
+	name:  "inspect",
+	args:  "PPN",
+	short: "print the SUDOC and Alma locations of a PPN side by side",
+	setup: func(fs *flag.FlagSet) func(args []string, stdout, stderr io.Writer) error {
+		config := configFlag(fs)
+		return func(args []string, stdout, stderr io.Writer) error { return inspect(*config, args, stdout) }
+	},
+}
+
+func inspect(config string, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("want one PPN: %w", errUsage)
+	}
+	ppn := args[0]
+	if !input.PPNPattern.MatchString(ppn) {
+		return fmt.Errorf("invalid PPN %q: %w", ppn, errUsage)
+	}
+
+	ctrl, err := controller.NewController(config, requests.NewHttpFetch(nil))
+	if err != nil {
+		return err
+	}
+	insp, err := ctrl.Inspect(context.Background(), ppn)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "PPN %s - MMS %s\n\n", insp.Record.PPN, insp.Record.MMS)
+	if insp.Skipped != "" {
+		fmt.Fprintf(stdout, "Notice non vérifiée par check : %s\n\n", insp.Skipped)
+	}
+	if insp.NotInAlma {
+		fmt.Fprintf(stdout, "PPN absent d'Alma : check ne compare pas ses localisations\n\n")
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RCR\tEPN\tSous-localisation\tCote\tStatut SUDOC\t|\tBibliothèque\tLocalisation\tCote\tExemplaires\tMasquée\tStatut Alma")
+	for _, row := range inspectRows(insp) {
+		var sudoc [5]string
+		var alma [6]string
+		if s := row.sudoc; s != nil {
+			sudoc = [5]string{s.RCR, s.EPN, s.Sublocation, s.CallNumber, s.Status()}
+		}
+		if a := row.alma; a != nil {
+			var items []string
+			for _, item := range a.Items {
+				items = append(items, item.Process_code+"/"+item.Status)
+			}
+			hidden := ""
+			if a.NoDiscovery {
+				hidden = "oui"
+			}
+			alma = [6]string{a.Library_code, a.Location_code, a.Call_number, strings.Join(items, " "), hidden, a.Status()}
+		}
+		fmt.Fprintf(w, "%s\t|\t%s\n", strings.Join(sudoc[:], "\t"), strings.Join(alma[:], "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if insp.NotInAlma {
+		return nil
+	}
+	fmt.Fprintf(stdout, "\n%d anomalie(s)\n", len(insp.Anomalies))
+	for _, a := range insp.Anomalies {
+		fmt.Fprintf(stdout, "%s : RCR %s %s%s %s\n", a.Type.Label(), a.RCR, a.SudocLib, a.AlmaLib, acknowledged(a))
+	}
+	return nil
+}
+
+// inspectRow is a line of the side by side view: matching locations are on
+// the same line.
+type inspectRow struct {
+	sudoc *controller.InspectedSudoc
+	alma  *controller.InspectedAlma
+}
+
+func inspectRows(insp *controller.Inspection) []inspectRow {
+	var rows []inspectRow
+	placed := make([]bool, len(insp.Alma))
+	for i := range insp.Sudoc {
+		row := inspectRow{sudoc: &insp.Sudoc[i]}
+		for j := range insp.Alma {
+			if !placed[j] && slices.Contains(insp.Sudoc[i].Matches, insp.Alma[j].Library_code) {
+				row.alma = &insp.Alma[j]
+				placed[j] = true
+				break
+			}
+		}
+		rows = append(rows, row)
+	}
+	for j := range insp.Alma {
+		if !placed[j] {
+			rows = append(rows, inspectRow{alma: &insp.Alma[j]})
+		}
+	}
+	return rows
+}
+
+func acknowledged(s controller.Summary) string {
+	if !s.Acknowledged {
+		return ""
+	}
+	return "(exception : " + s.Comment + ")"
+}
@@ -1,103 +1,152 @@
 // casl is a tool to compare locations of bibliographic resources between SUDOC
 // and Alma.
+//
+// Usage:
+//
+//	casl <command> [flags] [arguments]
+//
+// Run "casl help" for the list of commands.
 package main
 
 import (
-	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"regexp"
-	"time"
-
-	"casl/controller"
-	"casl/entities"
-	"casl/requests"
 )
 
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: casl file1 file2...")
-		log.Fatal("casl: called without arguments")
-	}
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
 
-	start := time.Now()
+// errUsage is returned by a command called with invalid arguments. Its usage is
+// printed and casl exits with exitUsage.
+var errUsage = errors.New("invalid usage")
 
-	fetcher := requests.NewHttpFetch(nil)
-	ctrl, err := controller.NewController("config.json", fetcher)
-	if err != nil {
-		log.Fatal(err)
-	}
+// command is a casl subcommand. setup declares the flags of the command and
+// returns the function running it with the remaining arguments. The command
+// writes its output to stdout and its messages to stderr.
+type command struct {
+	name  string
+	args  string
+	short string
+	setup func(fs *flag.FlagSet) func(args []string, stdout, stderr io.Writer) error
+}
 
-	// PPNs to check.
-	ppns := make(map[string]bool)
-	var records []entities.BibRecord
-	var ppnPattern = regexp.MustCompile(`[0-9]{8}([0-9]|(x|X))`)
+var commands = []*command{
+	checkCmd,
+	inspectCmd,
+	mappingsCmd,
+	validateConfigCmd,
+	reportCmd,
+}
 
-	for _, filename := range os.Args[1:] {
-		f, err := os.Open(filename)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer f.Close()
-		scanner := bufio.NewScanner(f)
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("casl: ")
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-		// Discard invalid ppns.
-		for scanner.Scan() {
-			if line := scanner.Text(); ppnPattern.Match([]byte(line)) {
-				ppns[line] = true
-				records = append(records, entities.BibRecord{PPN: line})
-			} else {
-				log.Printf("invalid PPN: %s", line)
+// run executes the command line and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		usage(stderr)
+		return exitUsage
+	}
+	name := args[0]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 1 {
+			if cmd := findCommand(args[1]); cmd != nil {
+				fs, _ := cmd.newFlagSet(stdout)
+				fs.Usage()
+				return exitOK
 			}
+			fmt.Fprintf(stderr, "casl help: unknown command %q\n", args[1])
+			return exitUsage
 		}
-		if err := scanner.Err(); err != nil {
-			log.Fatal(err)
-		}
+		usage(stdout)
+		return exitOK
 	}
 
-	fmt.Printf("%d PPN à vérifier...\n", len(ppns))
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(stderr, "casl: unknown command %q\n", name)
+		fmt.Fprintln(stderr, `Run "casl help" for usage. To check PPN files, use "casl check file...".`)
+		return exitUsage
+	}
 
-	var results []entities.BibRecord
-	for i, record := range records {
-		fmt.Printf("ppn %d/%d...\n", i, len(records))
-		sudoc, err := ctrl.SUClient.GetFilteredLocations(record.PPN, ctrl.Config.FollowedRCR)
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-		if len(sudoc) > 0 {
-			record.SudocLocations = sudoc
+	fs, runCmd := cmd.newFlagSet(stderr)
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
 		}
-		alma, err := ctrl.AlmaClient.GetFilteredLocations(record.PPN, ctrl.Config.FolowedLibs, ctrl.Config.IgnoredAlmaColl)
-		if err != nil {
-			log.Println(err)
-			continue
+		return exitUsage
+	}
+	if err := runCmd(fs.Args(), stdout, stderr); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "casl %s: %v\n", cmd.name, err)
+			fs.Usage()
+			return exitUsage
 		}
-		if len(alma) > 0 {
-			record.AlmaLocations = alma
+		fmt.Fprintf(stderr, "casl %s: %v\n", cmd.name, err)
+		return exitError
+	}
+	return exitOK
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
 		}
-		results = append(results, record)
 	}
+	return nil
+}
 
-	var sums []controller.Summary
-	for _, res := range results {
-		sums = append(sums, ctrl.Compare(&res)...)
+// newFlagSet returns the flag set of the command, with its usage text, and
+// the function running the command.
+func (cmd *command) newFlagSet(output io.Writer) (*flag.FlagSet, func([]string, io.Writer, io.Writer) error) {
+	fs := flag.NewFlagSet("casl "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	runCmd := cmd.setup(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: casl %s [flags] %s\n\n%s\n", cmd.name, cmd.args, cmd.short)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(fs.Output(), "\nFlags:")
+			fs.PrintDefaults()
+		}
 	}
+	return fs, runCmd
+}
 
-	ctrl.WriteCSV(sums)
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "casl compares locations of bibliographic resources between SUDOC and Alma.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage: casl <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.short)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "casl help <command>" for the flags of a command.`)
+}
 
-	elapsed := time.Since(start)
-	fmt.Printf("Elapsed time: %s\n", elapsed)
+// newLogger returns a logger writing to w, with the prefix and flags of the
+// standard logger.
+func newLogger(w io.Writer) *log.Logger {
+	return log.New(w, log.Prefix(), log.Flags())
+}
 
-	fmt.Println()
-	fmt.Println("ALMA STATS")
-	fmt.Printf("bibs: %d\n", ctrl.AlmaClient.Stats("bibs"))
-	fmt.Printf("items: %d\n", ctrl.AlmaClient.Stats("items"))
-	fmt.Printf("total: %d\n", ctrl.AlmaClient.Stats("total"))
-	fmt.Println()
-	fmt.Println("SUDOC STATS")
-	fmt.Printf("iln2rcr: %d\n", ctrl.SUClient.Stats("iln2rcr"))
-	fmt.Printf("marcxml: %d\n", ctrl.SUClient.Stats("marcxml"))
-	fmt.Printf("total: %d\n", ctrl.SUClient.Stats("total"))
+// configFlag adds the -config flag, shared by every command.
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", "config.json", "configuration file")
 }
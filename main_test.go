@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"casl/controller"
+	"casl/history"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "casl.db")
+	store, err := history.Open(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{"no command", nil, exitUsage, "", "Usage: casl <command>"},
+		{"help", []string{"help"}, exitOK, "Commands:", ""},
+		{"command help", []string{"help", "inspect"}, exitOK, "Usage: casl inspect", ""},
+		{"help of an unknown command", []string{"help", "nope"}, exitUsage, "", `unknown command "nope"`},
+		{"unknown command", []string{"nope"}, exitUsage, "", `unknown command "nope"`},
+		{"unknown flag", []string{"check", "-nope"}, exitUsage, "", "Usage: casl check"},
+		{"flag help", []string{"check", "-h"}, exitOK, "", "Usage: casl check"},
+		{"missing file", []string{"check"}, exitUsage, "", "casl check: no PPN file: invalid usage"},
+		{"invalid format", []string{"check", "-format", "pdf", "ppns.txt"}, exitUsage, "", "unknown output format"},
+		{"missing PPN", []string{"inspect"}, exitUsage, "", "want one PPN"},
+		{"invalid PPN", []string{"inspect", "123"}, exitUsage, "", "invalid PPN"},
+		{"unexpected argument", []string{"mappings", "extra"}, exitUsage, "", "Usage: casl mappings"},
+		{"report without input", []string{"report"}, exitUsage, "", "want either a results file or -history"},
+		{"missing configuration", []string{"validate-config", "-config", filepath.Join(dir, "none.json")},
+			exitError, "", "casl validate-config:"},
+		{"unknown run", []string{"report", "-history", db, "-run", "9999"}, exitError, "", "run 9999: history: run not found"},
+		{"output directory on a file", []string{"check", "-outdir", db, "ppns.txt"}, exitError, "", "output directory:"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(test.args, &stdout, &stderr); code != test.code {
+				t.Errorf("want exit code %d, got %d (stderr %q)", test.code, code, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), test.stdout) {
+				t.Errorf("want %q in stdout, got %q", test.stdout, stdout.String())
+			}
+			if !strings.Contains(stderr.String(), test.stderr) {
+				t.Errorf("want %q in stderr, got %q", test.stderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestOutputDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "rapports", "2024")
+	format, groupBy, split := controller.FormatCSV, controller.GroupByILN, false
+	opts := outputOptions{format: &format, groupBy: &groupBy, split: &split, dir: &dir}
+	if err := opts.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("want the output directory created, got %v", err)
+	}
+}
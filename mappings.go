@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"casl/controller"
+	"casl/requests"
+)
+
+var mappingsCmd = &command{
+	name:  "mappings",
+	args:  "",
+	short: "dump, validate or propose the alma-rcr mapping file",
+	setup: func(fs *flag.FlagSet) func(args []string, stdout, stderr io.Writer) error {
+		config := configFlag(fs)
+		quiet := fs.Bool("q", false, "only validate, do not dump the mapping")
+		online := fs.Bool("check", false, "also check the mapping against the Alma and SUDOC libraries")
+		propose := fs.Bool("propose", false, "print a mapping proposed from the Alma and SUDOC libraries")
+		return func(args []string, stdout, stderr io.Writer) error {
+			if len(args) != 0 {
+				return fmt.Errorf("unexpected arguments: %w", errUsage)
+			}
+			if *propose {
+				return proposeMappings(*config, stdout)
+			}
+			return dumpMappings(*config, *quiet, *online, stdout, stderr)
+		}
+	},
+}
+
+// dumpMappings prints the mapping as CSV, with a header, then its problems on
+// the standard error. Online, the mapping is also checked against the Alma and
+// SUDOC libraries.
+func dumpMappings(config string, quiet, online bool, stdout, stderr io.Writer) error {
+	var ctrl controller.Controller
+	var err error
+	if online {
+		ctrl, err = controller.NewController(config, requests.NewHttpFetch(nil))
+	} else {
+		ctrl, err = controller.NewOfflineController(config)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !quiet {
+		w := csv.NewWriter(stdout)
+		w.Write(append([]string{"line"}, controller.MappingHeader...))
+		for _, row := range ctrl.MappingRows() {
+			w.Write(append([]string{fmt.Sprint(row.Line)}, mappingRecord(row)...))
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+
+	errs := ctrl.ValidateMappings()
+	var failures []error
+	if online {
+		check, err := ctrl.CheckMappings(context.Background())
+		if err != nil {
+			return err
+		}
+		errs = append(errs, check.Problems...)
+		failures = check.Failures
+	}
+	// Lookup failures are not problems of the mapping, and are reported apart.
+	for _, err := range failures {
+		fmt.Fprintln(stderr, err)
+	}
+	if err := reportProblems(stderr, errs); err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d RCR(s) could not be checked", len(failures))
+	}
+	return nil
+}
+
+// proposeMappings prints a mapping in the format 2 of the alma-rcr file, to be
+// reviewed: Alma libraries without RCR have empty RCR and ILN columns, those
+// matching several RCRs have several lines.
+func proposeMappings(config string, stdout io.Writer) error {
+	ctrl, err := controller.NewMappingController(config, requests.NewHttpFetch(nil))
+	if err != nil {
+		return err
+	}
+	rows, err := ctrl.ProposeMappings(context.Background())
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(stdout)
+	w.Write(controller.MappingHeader)
+	for _, row := range rows {
+		w.Write(mappingRecord(row))
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// mappingRecord returns the columns of a mapping row in the format 2 file.
+func mappingRecord(row controller.MappingRow) []string {
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02")
+	}
+	return []string{row.AlmaName, row.AlmaCode, row.AlmaLocation, row.RCR, row.ILN,
+		row.SudocName, date(row.ValidFrom), date(row.ValidTo), row.Comment}
+}
+
+// reportProblems prints the problems found by a validation to w and returns an
+// error if there is any.
+func reportProblems(w io.Writer, errs []error) error {
+	for _, err := range errs {
+		fmt.Fprintln(w, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d problem(s) found", len(errs))
+	}
+	return nil
+}
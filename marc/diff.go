@@ -0,0 +1,168 @@
+package marc
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ChangeType is the kind of difference between two records for a field.
+type ChangeType string
+
+const (
+	Added   ChangeType = "added"
+	Removed ChangeType = "removed"
+	Changed ChangeType = "changed"
+)
+
+// FieldChange is a difference between two records. Old is nil for an added
+// field, New for a removed one. The leader is compared as a control field of
+// tag LDR.
+type FieldChange struct {
+	Type ChangeType
+	Tag  string
+	// Occurrence is the occurrence of the field among the fields of the same
+	// tag, from 0, in the old record, or in the new one for an added field.
+	Occurrence int
+	Old, New   Field
+}
+
+func (c FieldChange) String() string {
+	switch c.Type {
+	case Added:
+		return fmt.Sprintf("+ %s[%d] %s", c.Tag, c.Occurrence, fieldText(c.New))
+	case Removed:
+		return fmt.Sprintf("- %s[%d] %s", c.Tag, c.Occurrence, fieldText(c.Old))
+	default:
+		return fmt.Sprintf("~ %s[%d] %s -> %s", c.Tag, c.Occurrence, fieldText(c.Old), fieldText(c.New))
+	}
+}
+
+// fieldText returns a field in the mnemonic format, without tag.
+func fieldText(f Field) string {
+	switch f := f.(type) {
+	case *Controlfield:
+		return f.Value
+	case *Datafield:
+		var sb strings.Builder
+		sb.WriteString(mrkIndicator(f.Ind1) + mrkIndicator(f.Ind2))
+		for _, sub := range f.Subfields {
+			sb.WriteString("$" + sub.Code + sub.Value)
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+// Diff compares two records field by field, for each tag in order. Repeated
+// fields are aligned on the identical ones, the others are paired in order as
+// changed fields, the extra ones being added or removed.
+func Diff(old, new *Record) []FieldChange {
+	var changes []FieldChange
+	if old.Leader != new.Leader {
+		changes = append(changes, FieldChange{Type: Changed, Tag: "LDR",
+			Old: &Controlfield{Tag: "LDR", Value: old.Leader}, New: &Controlfield{Tag: "LDR", Value: new.Leader}})
+	}
+
+	oldFields, newFields := fieldsByTag(old), fieldsByTag(new)
+	var tags []string
+	for tag := range oldFields {
+		tags = append(tags, tag)
+	}
+	for tag := range newFields {
+		if _, ok := oldFields[tag]; !ok {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	for _, tag := range tags {
+		changes = append(changes, diffFields(tag, oldFields[tag], newFields[tag])...)
+	}
+	return changes
+}
+
+// fieldsByTag returns the fields of the record grouped by tag.
+func fieldsByTag(r *Record) map[string][]Field {
+	fields := make(map[string][]Field)
+	for i := range r.Controlfields {
+		cf := &r.Controlfields[i]
+		fields[cf.Tag] = append(fields[cf.Tag], cf)
+	}
+	for i := range r.Datafields {
+		df := &r.Datafields[i]
+		fields[df.Tag] = append(fields[df.Tag], df)
+	}
+	return fields
+}
+
+// diffFields compares fields of the same tag, aligned by their longest common
+// subsequence.
+func diffFields(tag string, old, new []Field) []FieldChange {
+	// lcs[i][j] is the length of the longest common subsequence of old[i:]
+	// and new[j:].
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if sameField(old[i], new[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var changes []FieldChange
+	var removed, added []int
+	flush := func() {
+		n := min(len(removed), len(added))
+		for k := 0; k < n; k++ {
+			changes = append(changes, FieldChange{Type: Changed, Tag: tag, Occurrence: removed[k],
+				Old: old[removed[k]], New: new[added[k]]})
+		}
+		for _, i := range removed[n:] {
+			changes = append(changes, FieldChange{Type: Removed, Tag: tag, Occurrence: i, Old: old[i]})
+		}
+		for _, j := range added[n:] {
+			changes = append(changes, FieldChange{Type: Added, Tag: tag, Occurrence: j, New: new[j]})
+		}
+		removed, added = removed[:0], added[:0]
+	}
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && j < len(new) && sameField(old[i], new[j]):
+			flush()
+			i++
+			j++
+		case j == len(new) || (i < len(old) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, i)
+			i++
+		default:
+			added = append(added, j)
+			j++
+		}
+	}
+	flush()
+	return changes
+}
+
+// sameField tells if two fields have the same content. Empty and blank
+// indicators are equal.
+func sameField(a, b Field) bool {
+	switch a := a.(type) {
+	case *Controlfield:
+		b, ok := b.(*Controlfield)
+		return ok && a.Tag == b.Tag && a.Value == b.Value
+	case *Datafield:
+		b, ok := b.(*Datafield)
+		return ok && a.Tag == b.Tag && blankIndicator(a.Ind1) == blankIndicator(b.Ind1) &&
+			blankIndicator(a.Ind2) == blankIndicator(b.Ind2) &&
+			slices.EqualFunc(a.Subfields, b.Subfields, func(x, y Subfield) bool {
+				return x.Code == y.Code && x.Value == y.Value
+			})
+	}
+	return false
+}
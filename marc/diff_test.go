@@ -0,0 +1,48 @@
+package marc
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old, err := NewRecord(correctXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	new, err := NewRecord(correctXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := Diff(old, new); len(changes) != 0 {
+		t.Fatalf("identical records: want no change, got %v", changes)
+	}
+
+	new.Leader = "     nam0 22        450 "
+	new.RemoveField("702", 0)
+	new.Datafields[0].SetSubfield("a", "978-2-253-02983-1")
+	new.AddField(&Datafield{Tag: "930", Subfields: []Subfield{{Code: "5", Value: "ETAB5"}}})
+	new.RemoveField("008", 1)
+
+	var got []string
+	for _, c := range Diff(old, new) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"~ LDR[0]      cam0 22        450  ->      nam0 22        450 ",
+		"- 008[1] Oay3",
+		`~ 010[0] \\$a978-2-253-02983-0 -> \\$a978-2-253-02983-1`,
+		`- 702[0] \1$302704324X$aPappo-Musard$bCatherine$4730`,
+		`+ 930[2] \\$5ETAB5`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("want\n%q\ngot\n%q", want, got)
+	}
+
+	// "#" is a blank indicator.
+	new, _ = NewRecord(correctXML)
+	new.Datafields[0].Ind1, new.Datafields[0].Ind2 = "#", "#"
+	if changes := Diff(old, new); len(changes) != 0 {
+		t.Errorf("blank indicators: want no change, got %v", changes)
+	}
+}
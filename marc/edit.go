@@ -0,0 +1,149 @@
+package marc
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// AddField appends a copy of a field to the record: a *Controlfield or a
+// *Datafield.
+func (r *Record) AddField(f Field) error {
+	switch f := f.(type) {
+	case *Controlfield:
+		if !isControlTag(f.Tag) {
+			return fmt.Errorf("AddField: invalid control field tag %q", f.Tag)
+		}
+		r.Controlfields = append(r.Controlfields, Controlfield{Tag: f.Tag, Value: f.Value})
+	case *Datafield:
+		if len(f.Tag) != 3 || isControlTag(f.Tag) {
+			return fmt.Errorf("AddField: invalid data field tag %q", f.Tag)
+		}
+		df := *f
+		df.Subfields = slices.Clone(f.Subfields)
+		r.Datafields = append(r.Datafields, df)
+	default:
+		return fmt.Errorf("AddField: unsupported field %T", f)
+	}
+	return nil
+}
+
+// InsertField adds a copy of a field to the record after the last field whose
+// tag is lower or equal, keeping the fields sorted.
+func (r *Record) InsertField(f Field) error {
+	if err := r.AddField(f); err != nil {
+		return err
+	}
+	switch f.(type) {
+	case *Controlfield:
+		n := len(r.Controlfields) - 1
+		i := sort.Search(n, func(i int) bool { return r.Controlfields[i].Tag > r.Controlfields[n].Tag })
+		cf := r.Controlfields[n]
+		copy(r.Controlfields[i+1:], r.Controlfields[i:n])
+		r.Controlfields[i] = cf
+	case *Datafield:
+		n := len(r.Datafields) - 1
+		i := sort.Search(n, func(i int) bool { return r.Datafields[i].Tag > r.Datafields[n].Tag })
+		df := r.Datafields[n]
+		copy(r.Datafields[i+1:], r.Datafields[i:n])
+		r.Datafields[i] = df
+	}
+	return nil
+}
+
+// RemoveField removes the occurrence (from 0) of the field of given tag. It
+// returns false if there is no such field.
+func (r *Record) RemoveField(tag string, occurrence int) bool {
+	if isControlTag(tag) {
+		if i := nthIndex(r.Controlfields, occurrence, func(cf Controlfield) bool { return cf.Tag == tag }); i >= 0 {
+			r.Controlfields = slices.Delete(r.Controlfields, i, i+1)
+			return true
+		}
+		return false
+	}
+	if i := nthIndex(r.Datafields, occurrence, func(df Datafield) bool { return df.Tag == tag }); i >= 0 {
+		r.Datafields = slices.Delete(r.Datafields, i, i+1)
+		return true
+	}
+	return false
+}
+
+// RemoveFields removes all the fields of given tag and returns their number.
+func (r *Record) RemoveFields(tag string) int {
+	n := len(r.Controlfields) + len(r.Datafields)
+	r.Controlfields = slices.DeleteFunc(r.Controlfields, func(cf Controlfield) bool { return cf.Tag == tag })
+	r.Datafields = slices.DeleteFunc(r.Datafields, func(df Datafield) bool { return df.Tag == tag })
+	return n - len(r.Controlfields) - len(r.Datafields)
+}
+
+// MoveDatafield moves the data field at index from to index to, shifting the
+// fields in between.
+func (r *Record) MoveDatafield(from, to int) error {
+	if from < 0 || from >= len(r.Datafields) || to < 0 || to >= len(r.Datafields) {
+		return fmt.Errorf("MoveDatafield: index out of range [0,%d)", len(r.Datafields))
+	}
+	df := r.Datafields[from]
+	r.Datafields = slices.Insert(slices.Delete(r.Datafields, from, from+1), to, df)
+	return nil
+}
+
+// SortFields sorts the fields by tag, keeping the order of fields with the
+// same tag.
+func (r *Record) SortFields() {
+	slices.SortStableFunc(r.Controlfields, func(a, b Controlfield) int { return strings.Compare(a.Tag, b.Tag) })
+	slices.SortStableFunc(r.Datafields, func(a, b Datafield) int { return strings.Compare(a.Tag, b.Tag) })
+}
+
+// AddSubfield appends a subfield.
+func (df *Datafield) AddSubfield(code, value string) {
+	df.Subfields = append(df.Subfields, Subfield{Code: code, Value: value})
+}
+
+// SetSubfield sets the value of the first subfield of given code, or appends
+// the subfield if there is none.
+func (df *Datafield) SetSubfield(code, value string) {
+	for i := range df.Subfields {
+		if df.Subfields[i].Code == code {
+			df.Subfields[i].Value = value
+			return
+		}
+	}
+	df.AddSubfield(code, value)
+}
+
+// RemoveSubfields removes the subfields of given code and returns their
+// number.
+func (df *Datafield) RemoveSubfields(code string) int {
+	n := len(df.Subfields)
+	df.Subfields = slices.DeleteFunc(df.Subfields, func(sub Subfield) bool { return sub.Code == code })
+	return n - len(df.Subfields)
+}
+
+// SortSubfields sorts the subfields in the order of the given codes, the
+// subfields whose code is not listed last; the order of subfields with the same
+// rank is kept.
+func (df *Datafield) SortSubfields(codes string) {
+	rank := func(sub Subfield) int {
+		if len(sub.Code) == 1 {
+			if i := strings.IndexByte(codes, sub.Code[0]); i >= 0 {
+				return i
+			}
+		}
+		return len(codes)
+	}
+	slices.SortStableFunc(df.Subfields, func(a, b Subfield) int { return rank(a) - rank(b) })
+}
+
+// nthIndex returns the index of the nth element (from 0) matching f, or -1.
+func nthIndex[E any](s []E, n int, f func(E) bool) int {
+	for i, e := range s {
+		if f(e) {
+			if n == 0 {
+				return i
+			}
+			n--
+		}
+	}
+	return -1
+}
@@ -0,0 +1,74 @@
+package marc
+
+import (
+	"slices"
+	"testing"
+)
+
+func tags(r *Record) []string {
+	var res []string
+	for _, cf := range r.Controlfields {
+		res = append(res, cf.Tag)
+	}
+	for _, df := range r.Datafields {
+		res = append(res, df.Tag)
+	}
+	return res
+}
+
+func TestEdit(t *testing.T) {
+	var r Record
+	for _, f := range []Field{
+		&Datafield{Tag: "930"},
+		&Controlfield{Tag: "005", Value: "x"},
+		&Datafield{Tag: "200"},
+		&Controlfield{Tag: "001", Value: "123456789"},
+		&Datafield{Tag: "700"},
+		&Datafield{Tag: "200", Ind1: "1"},
+	} {
+		if err := r.InsertField(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"001", "005", "200", "200", "700", "930"}; !slices.Equal(tags(&r), want) {
+		t.Errorf("InsertField: want %v, got %v", want, tags(&r))
+	}
+	if r.Datafields[1].Ind1 != "1" {
+		t.Error("InsertField: want new field after the existing ones of the same tag")
+	}
+	if err := r.AddField(&Controlfield{Tag: "200"}); err == nil {
+		t.Error("AddField: want error on a control field with a data field tag")
+	}
+
+	if !r.RemoveField("200", 1) || r.RemoveField("200", 1) || len(r.Datafields) != 3 {
+		t.Errorf("RemoveField: unexpected fields %v", tags(&r))
+	}
+	if err := r.MoveDatafield(2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"001", "005", "930", "200", "700"}; !slices.Equal(tags(&r), want) {
+		t.Errorf("MoveDatafield: want %v, got %v", want, tags(&r))
+	}
+	r.SortFields()
+	if want := []string{"001", "005", "200", "700", "930"}; !slices.Equal(tags(&r), want) {
+		t.Errorf("SortFields: want %v, got %v", want, tags(&r))
+	}
+	if n := r.RemoveFields("005"); n != 1 {
+		t.Errorf("RemoveFields: want 1, got %d", n)
+	}
+
+	df := &r.Datafields[0]
+	df.AddSubfield("f", "Virginia Woolf")
+	df.AddSubfield("a", "Orlando")
+	df.AddSubfield("f", "Catherine Pappo")
+	df.SetSubfield("e", "roman")
+	df.SetSubfield("a", "Orlando : a biography")
+	df.SortSubfields("ae")
+	if got := MustCompile("200$aef").Values(&r); !slices.Equal(got,
+		[]string{"Orlando : a biography", "roman", "Virginia Woolf", "Catherine Pappo"}) {
+		t.Errorf("subfields: unexpected values %q", got)
+	}
+	if n := df.RemoveSubfields("f"); n != 2 || len(df.Subfields) != 2 {
+		t.Errorf("RemoveSubfields: want 2 removed, got %d", n)
+	}
+}
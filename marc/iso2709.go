@@ -0,0 +1,313 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ISO 2709 delimiters.
+const (
+	SubfieldDelimiter = 0x1F
+	FieldTerminator   = 0x1E
+	RecordTerminator  = 0x1D
+)
+
+const leaderLength = 24
+
+// Encoding is the character encoding of ISO 2709 records.
+type Encoding int
+
+const (
+	// EncodingAuto reads records as UTF-8 if leader position 9 is "a" or if
+	// the record is valid UTF-8, and as MARC-8 otherwise.
+	EncodingAuto Encoding = iota
+	// EncodingUTF8 reads records as UTF-8, whatever their leader.
+	EncodingUTF8
+	// EncodingMARC8 reads records as MARC-8, whatever their leader.
+	EncodingMARC8
+)
+
+// ISO2709Reader reads records from ISO 2709 (binary MARC) data. Records are
+// always returned in UTF-8: the leader position 9 of records converted from
+// MARC-8 is set to "a".
+type ISO2709Reader struct {
+	Encoding Encoding
+	r        *bufio.Reader
+	// n is the number of records read.
+	n int
+}
+
+// NewISO2709Reader returns a reader of the records of r.
+func NewISO2709Reader(r io.Reader) *ISO2709Reader {
+	return &ISO2709Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record, or io.EOF when there is no more record.
+func (ir *ISO2709Reader) Read() (*Record, error) {
+	// Skip the line breaks some tools add between records.
+	for {
+		b, err := ir.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != '\n' && b[0] != '\r' {
+			break
+		}
+		ir.r.ReadByte()
+	}
+	ir.n++
+
+	prefix, err := ir.r.Peek(5)
+	if err != nil {
+		return nil, fmt.Errorf("Read: record %d: truncated record length", ir.n)
+	}
+	length, err := strconv.Atoi(string(prefix))
+	if err != nil || length < leaderLength+1 {
+		return nil, fmt.Errorf("Read: record %d: invalid record length %q", ir.n, prefix)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(ir.r, data); err != nil {
+		return nil, fmt.Errorf("Read: record %d: truncated record: %w", ir.n, err)
+	}
+	record, err := parseISO2709(data, ir.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("Read: record %d: %w", ir.n, err)
+	}
+	return record, nil
+}
+
+// ReadAll returns all the remaining records.
+func (ir *ISO2709Reader) ReadAll() ([]*Record, error) {
+	var records []*Record
+	for {
+		record, err := ir.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+// Unmarshal parses a single ISO 2709 record, with the EncodingAuto rules.
+func Unmarshal(data []byte) (*Record, error) {
+	if len(data) < leaderLength+1 {
+		return nil, errors.New("Unmarshal: truncated record")
+	}
+	record, err := parseISO2709(data, EncodingAuto)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshal: %w", err)
+	}
+	return record, nil
+}
+
+// parseISO2709 parses a single record, terminator included.
+func parseISO2709(data []byte, encoding Encoding) (*Record, error) {
+	if data[len(data)-1] != RecordTerminator {
+		return nil, errors.New("missing record terminator")
+	}
+	leader := string(data[:leaderLength])
+	marc8 := encoding == EncodingMARC8 ||
+		encoding == EncodingAuto && leader[9] != 'a' && !utf8.Valid(data)
+	decode := func(b []byte) (string, error) {
+		if marc8 {
+			return DecodeMARC8(b)
+		}
+		return string(b), nil
+	}
+	if marc8 {
+		leader = leader[:9] + "a" + leader[10:]
+	}
+	base, err := strconv.Atoi(leader[12:17])
+	if err != nil || base <= leaderLength || base > len(data) {
+		return nil, fmt.Errorf("invalid base address of data %q", leader[12:17])
+	}
+	indicators := 2
+	if n, err := strconv.Atoi(leader[10:11]); err == nil {
+		indicators = n
+	}
+
+	directory := data[leaderLength : base-1]
+	if data[base-1] != FieldTerminator || len(directory)%12 != 0 {
+		return nil, errors.New("invalid directory")
+	}
+
+	record := Record{Leader: leader}
+	for i := 0; i < len(directory); i += 12 {
+		entry := directory[i : i+12]
+		tag := string(entry[:3])
+		length, ok1 := parseDigits(entry[3:7])
+		start, ok2 := parseDigits(entry[7:12])
+		if !ok1 || !ok2 || base+start+length > len(data)-1 || length < 1 {
+			return nil, fmt.Errorf("invalid directory entry %q", entry)
+		}
+		field := data[base+start : base+start+length]
+		// Remove the field terminator.
+		field = bytes.TrimSuffix(field, []byte{FieldTerminator})
+
+		if isControlTag(tag) {
+			value, err := decode(field)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", tag, err)
+			}
+			record.Controlfields = append(record.Controlfields, Controlfield{Tag: tag, Value: value})
+			continue
+		}
+		df := Datafield{Tag: tag, Ind1: " ", Ind2: " "}
+		if len(field) < indicators {
+			return nil, fmt.Errorf("field %s: missing indicators", tag)
+		}
+		if indicators >= 1 {
+			df.Ind1 = string(field[0])
+		}
+		if indicators >= 2 {
+			df.Ind2 = string(field[1])
+		}
+		subfields := bytes.Split(field[indicators:], []byte{SubfieldDelimiter})
+		// The data before the first delimiter is empty in a valid field.
+		for _, sub := range subfields[1:] {
+			if len(sub) == 0 {
+				continue
+			}
+			value, err := decode(sub[1:])
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", tag, err)
+			}
+			df.Subfields = append(df.Subfields, Subfield{Code: string(sub[:1]), Value: value})
+		}
+		record.Datafields = append(record.Datafields, df)
+	}
+	return &record, nil
+}
+
+// parseDigits reads a number written with ASCII digits only: unlike
+// strconv.Atoi, it rejects signs, so that an offset cannot be negative.
+func parseDigits(b []byte) (int, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	n := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+// isControlTag tells if the tag is a control field: 001 to 009.
+func isControlTag(tag string) bool {
+	return len(tag) == 3 && tag[0] == '0' && tag[1] == '0'
+}
+
+// Limits of the ISO 2709 format, given by the sizes of the length fields.
+const (
+	maxRecordLength = 99999
+	maxFieldLength  = 9999
+)
+
+// Marshal encodes a record in ISO 2709. The record length, the base address of
+// data and the entry map of the leader are computed; the other positions of the
+// leader are kept, a missing leader is filled with blanks. Control fields are
+// written before data fields. Values are written as they are, in UTF-8.
+func Marshal(r *Record) ([]byte, error) {
+	var directory, data bytes.Buffer
+	addField := func(tag string, content []byte) error {
+		if len(tag) != 3 {
+			return fmt.Errorf("Marshal: invalid tag %q", tag)
+		}
+		content = append(content, FieldTerminator)
+		if len(content) > maxFieldLength {
+			return fmt.Errorf("Marshal: field %s: length %d exceeds %d", tag, len(content), maxFieldLength)
+		}
+		fmt.Fprintf(&directory, "%s%04d%05d", tag, len(content), data.Len())
+		data.Write(content)
+		return nil
+	}
+
+	for _, cf := range r.Controlfields {
+		if err := addField(cf.Tag, []byte(cf.Value)); err != nil {
+			return nil, err
+		}
+	}
+	for _, df := range r.Datafields {
+		var content bytes.Buffer
+		content.WriteString(indicator(df.Ind1))
+		content.WriteString(indicator(df.Ind2))
+		for _, sub := range df.Subfields {
+			if len(sub.Code) != 1 {
+				return nil, fmt.Errorf("Marshal: field %s: invalid subfield code %q", df.Tag, sub.Code)
+			}
+			content.WriteByte(SubfieldDelimiter)
+			content.WriteString(sub.Code)
+			content.WriteString(sub.Value)
+		}
+		if err := addField(df.Tag, content.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	directory.WriteByte(FieldTerminator)
+
+	base := leaderLength + directory.Len()
+	length := base + data.Len() + 1
+	if length > maxRecordLength {
+		return nil, fmt.Errorf("Marshal: record length %d exceeds %d", length, maxRecordLength)
+	}
+	leader := []byte(fmt.Sprintf("%-24s", r.Leader))[:leaderLength]
+	copy(leader[0:5], fmt.Sprintf("%05d", length))
+	copy(leader[10:12], "22")
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+	copy(leader[20:23], "450")
+
+	out := make([]byte, 0, length)
+	out = append(out, leader...)
+	out = append(out, directory.Bytes()...)
+	out = append(out, data.Bytes()...)
+	return append(out, RecordTerminator), nil
+}
+
+// indicator returns the indicator, blank if empty.
+func indicator(ind string) string {
+	if len(ind) != 1 {
+		return " "
+	}
+	return ind
+}
+
+// blankIndicator returns the indicator like indicator, with "#", which the
+// SUDOC uses for a blank, read as a blank. It is used to compare indicators,
+// writers keep the stored value.
+func blankIndicator(ind string) string {
+	if ind == "#" {
+		return " "
+	}
+	return indicator(ind)
+}
+
+// ISO2709Writer writes records in ISO 2709.
+type ISO2709Writer struct {
+	w io.Writer
+}
+
+// NewISO2709Writer returns a writer of records to w.
+func NewISO2709Writer(w io.Writer) *ISO2709Writer {
+	return &ISO2709Writer{w: w}
+}
+
+// Write writes a record.
+func (iw *ISO2709Writer) Write(r *Record) error {
+	data, err := Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = iw.w.Write(data)
+	return err
+}
@@ -0,0 +1,165 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// buildISO2709 encodes fields given as tag and content; data field contents
+// start with the indicators and use "$" as subfield delimiter.
+func buildISO2709(fields ...[2]string) []byte {
+	var directory, data bytes.Buffer
+	for _, f := range fields {
+		content := strings.ReplaceAll(f[1], "$", string(rune(SubfieldDelimiter))) + string(rune(FieldTerminator))
+		fmt.Fprintf(&directory, "%s%04d%05d", f[0], len(content), data.Len())
+		data.WriteString(content)
+	}
+	directory.WriteByte(FieldTerminator)
+	base := leaderLength + directory.Len()
+	length := base + data.Len() + 1
+	leader := fmt.Sprintf("%05dcam  22%05d   450 ", length, base)
+	return append([]byte(leader+directory.String()+data.String()), RecordTerminator)
+}
+
+func TestISO2709Reader(t *testing.T) {
+	var data []byte
+	data = append(data, buildISO2709([2]string{"001", "123456789"}, [2]string{"200", "1 $aOrlando$fVirginia Woolf"})...)
+	data = append(data, '\n')
+	data = append(data, buildISO2709([2]string{"001", "12345678X"}, [2]string{"035", "  $a(OCoLC)1234"})...)
+
+	r := NewISO2709Reader(bytes.NewReader(data))
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("want 2 records, got %d", len(records))
+	}
+	if got := records[0].GetField("001")[0].GetValue(""); got[0] != "123456789" {
+		t.Errorf("001: want 123456789, got %v", got)
+	}
+	df := records[0].Datafields[0]
+	if df.Tag != "200" || df.Ind1 != "1" || df.Ind2 != " " || len(df.Subfields) != 2 ||
+		df.Subfields[1] != (Subfield{Code: "f", Value: "Virginia Woolf"}) {
+		t.Errorf("unexpected field %+v", df)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("want io.EOF, got %v", err)
+	}
+
+	errorTests := map[string][]byte{
+		"length":     []byte("abcde"),
+		"truncated":  data[:30],
+		"terminator": append(buildISO2709([2]string{"001", "1"})[:39], 'x'),
+		"offset":     []byte("00048     22000371  450 0010010-9999\x1e12345678X\x1e\x1d"),
+		"sign":       []byte("00048     22000371  450 0010010+0000\x1e12345678X\x1e\x1d"),
+	}
+	for name, input := range errorTests {
+		if _, err := NewISO2709Reader(bytes.NewReader(input)).Read(); err == nil || err == io.EOF {
+			t.Errorf("%s: want error, got %v", name, err)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	// Round trip of a binary record.
+	data := buildISO2709([2]string{"001", "123456789"}, [2]string{"005", "20240101"},
+		[2]string{"200", "1 $aOrlando$fVirginia Woolf"}, [2]string{"930", "  $5ETAB1:EX1$aCôte"})
+	record, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("round trip:\nwant %q\ngot  %q", data, got)
+	}
+
+	// "#" indicators are written back as read.
+	hashed := buildISO2709([2]string{"001", "123456789"}, [2]string{"930", "##$5ETAB1:EX1"})
+	if record, err := Unmarshal(hashed); err != nil {
+		t.Error(err)
+	} else if got, err := Marshal(record); err != nil || !bytes.Equal(got, hashed) {
+		t.Errorf("round trip of # indicators:\nwant %q\ngot  %q, %v", hashed, got, err)
+	}
+
+	// Round trip of a MARCXML record.
+	xmlRecord, err := NewRecord(correctXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := NewISO2709Writer(&buf).Write(xmlRecord); err != nil {
+		t.Fatal(err)
+	}
+	record, err = NewISO2709Reader(&buf).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Controlfields) != 3 || len(record.Datafields) != 10 {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	for i, df := range record.Datafields {
+		want := xmlRecord.Datafields[i]
+		if df.Tag != want.Tag || df.Ind1 != want.Ind1 || df.Ind2 != want.Ind2 || len(df.Subfields) != len(want.Subfields) {
+			t.Errorf("field %d: want %+v, got %+v", i, want, df)
+		}
+	}
+	if record.Leader[5:10] != "cam0 " || record.Leader[20:24] != "450 " {
+		t.Errorf("unexpected leader %q", record.Leader)
+	}
+
+	long := Record{Datafields: []Datafield{{Tag: "300", Subfields: []Subfield{{Code: "a", Value: strings.Repeat("x", 10000)}}}}}
+	if _, err := Marshal(&long); err == nil {
+		t.Error("want error on a field too long")
+	}
+	if _, err := Marshal(&Record{Controlfields: []Controlfield{{Tag: "1", Value: "x"}}}); err == nil {
+		t.Error("want error on an invalid tag")
+	}
+}
+
+func TestMARC8(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"abc", "abc"},
+		{"\xe2ecole \xf0ca", "école ça"},
+		{"\xa5sop \xab\xb1\xa1", "Æsop ±łŁ"},
+		{"\x1b(Babc\x1b)!E\xe8u", "abcü"},
+	}
+	for _, test := range tests {
+		got, err := DecodeMARC8([]byte(test.input))
+		if err != nil {
+			t.Errorf("DecodeMARC8(%q): %v", test.input, err)
+		} else if got != test.want {
+			t.Errorf("DecodeMARC8(%q): want %q, got %q", test.input, test.want, got)
+		}
+	}
+	if _, err := DecodeMARC8([]byte("\x1b(Nabc")); !errors.Is(err, ErrUnsupportedCharset) {
+		t.Errorf("Cyrillic: want ErrUnsupportedCharset, got %v", err)
+	}
+
+	// A MARC-8 record is converted to UTF-8 and its leader updated.
+	record, err := Unmarshal(buildISO2709([2]string{"245", "10$a\xe2Ecole"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := record.Datafields[0].Subfields[0].Value; got != "École" || record.Leader[9] != 'a' {
+		t.Errorf("want École and leader/09 a, got %q, %q", got, record.Leader)
+	}
+	// UTF-8 records without "a" in the leader are left alone.
+	record, err = Unmarshal(buildISO2709([2]string{"200", "1 $aÉcole"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := record.Datafields[0].Subfields[0].Value; got != "École" || record.Leader[9] != ' ' {
+		t.Errorf("want École and blank leader/09, got %q, %q", got, record.Leader)
+	}
+}
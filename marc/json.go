@@ -0,0 +1,65 @@
+package marc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// MarshalJSON encodes a record in MARC-in-JSON:
+//
+//	{"leader": "...", "fields": [{"001": "..."},
+//	  {"200": {"ind1": "1", "ind2": " ", "subfields": [{"a": "..."}]}}]}
+//
+// Fields keep their order, control fields first.
+func MarshalJSON(r *Record) ([]byte, error) {
+	var buf bytes.Buffer
+	str := func(s string) {
+		b, _ := json.Marshal(s)
+		buf.Write(b)
+	}
+	buf.WriteString(`{"leader":`)
+	str(r.Leader)
+	buf.WriteString(`,"fields":[`)
+	for i, cf := range r.Controlfields {
+		if len(cf.Tag) != 3 {
+			return nil, fmt.Errorf("MarshalJSON: invalid tag %q", cf.Tag)
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('{')
+		str(cf.Tag)
+		buf.WriteByte(':')
+		str(cf.Value)
+		buf.WriteByte('}')
+	}
+	for i, df := range r.Datafields {
+		if len(df.Tag) != 3 {
+			return nil, fmt.Errorf("MarshalJSON: invalid tag %q", df.Tag)
+		}
+		if i > 0 || len(r.Controlfields) > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('{')
+		str(df.Tag)
+		buf.WriteString(`:{"ind1":`)
+		str(indicator(df.Ind1))
+		buf.WriteString(`,"ind2":`)
+		str(indicator(df.Ind2))
+		buf.WriteString(`,"subfields":[`)
+		for j, sub := range df.Subfields {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('{')
+			str(sub.Code)
+			buf.WriteByte(':')
+			str(sub.Value)
+			buf.WriteByte('}')
+		}
+		buf.WriteString("]}}")
+	}
+	buf.WriteString("]}")
+	return buf.Bytes(), nil
+}
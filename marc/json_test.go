@@ -0,0 +1,34 @@
+package marc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	record := Record{
+		Leader:        "     nam  22        450 ",
+		Controlfields: []Controlfield{{Tag: "001", Value: "123456789"}},
+		Datafields: []Datafield{
+			{Tag: "200", Ind1: "1", Subfields: []Subfield{{Code: "a", Value: `Orlando "1928"`}, {Code: "f", Value: "Virginia Woolf"}}},
+			{Tag: "930", Ind1: " ", Ind2: " ", Subfields: []Subfield{{Code: "5", Value: "ETAB1:EX1"}}},
+		},
+	}
+	got, err := MarshalJSON(&record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"leader":"     nam  22        450 ","fields":[{"001":"123456789"},` +
+		`{"200":{"ind1":"1","ind2":" ","subfields":[{"a":"Orlando \"1928\""},{"f":"Virginia Woolf"}]}},` +
+		`{"930":{"ind1":" ","ind2":" ","subfields":[{"5":"ETAB1:EX1"}]}}]}`
+	if string(got) != want {
+		t.Errorf("want %s\ngot  %s", want, got)
+	}
+	if !json.Valid(got) {
+		t.Error("invalid JSON")
+	}
+
+	if got, err := MarshalJSON(&Record{}); err != nil || string(got) != `{"leader":"","fields":[]}` {
+		t.Errorf("empty record: got %s, %v", got, err)
+	}
+}
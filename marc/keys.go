@@ -0,0 +1,150 @@
+package marc
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Keys are the elements identifying the work described by a bibliographic
+// record, normalized to be compared with the keys of another record.
+type Keys struct {
+	Title string
+	// ISBNs are converted to ISBN-13, without hyphens.
+	ISBNs []string
+	// Date is the first publication date of the fixed fields, if it is a
+	// year.
+	Date string
+	// IDs are the system control numbers (035$a), such as "(OCoLC)123".
+	IDs []string
+}
+
+var (
+	unimarcTitle = MustCompile("200$a")
+	unimarcISBN  = MustCompile("010$a")
+	marc21Title  = MustCompile("245$a")
+	marc21ISBN   = MustCompile("020$a")
+	controlIDs   = MustCompile("035$a")
+
+	yearPattern = regexp.MustCompile(`^[0-9]{4}$`)
+	isbnPattern = regexp.MustCompile(`^[0-9]{9}[0-9X]([0-9]{3})?`)
+)
+
+// Keys returns the identifying elements of the record, according to its
+// format.
+func (r *Record) Keys() Keys {
+	title, isbn := unimarcTitle, unimarcISBN
+	if r.Format() == FormatMARC21 {
+		title, isbn = marc21Title, marc21ISBN
+	}
+	k := Keys{Title: normalizeTitle(title.Value(r))}
+	for _, v := range isbn.Values(r) {
+		if n := normalizeISBN(v); n != "" && !slices.Contains(k.ISBNs, n) {
+			k.ISBNs = append(k.ISBNs, n)
+		}
+	}
+	if date := r.FixedFields().Date1; yearPattern.MatchString(date) {
+		k.Date = date
+	}
+	for _, v := range controlIDs.Values(r) {
+		if v = strings.TrimSpace(v); v != "" {
+			k.IDs = append(k.IDs, v)
+		}
+	}
+	return k
+}
+
+// Differences returns the elements which show that two records describe
+// different works: "title" if a title is not the beginning of the other one,
+// "isbn" if both have ISBNs but none in common, "date" if the years differ,
+// "035" if both have control numbers of a system but none in common. Missing
+// elements are not compared.
+func (k Keys) Differences(other Keys) []string {
+	var diff []string
+	if k.Title != "" && other.Title != "" &&
+		!strings.HasPrefix(k.Title, other.Title) && !strings.HasPrefix(other.Title, k.Title) {
+		diff = append(diff, "title")
+	}
+	if len(k.ISBNs) > 0 && len(other.ISBNs) > 0 &&
+		!slices.ContainsFunc(k.ISBNs, func(isbn string) bool { return slices.Contains(other.ISBNs, isbn) }) {
+		diff = append(diff, "isbn")
+	}
+	if k.Date != "" && other.Date != "" && k.Date != other.Date {
+		diff = append(diff, "date")
+	}
+	ids, otherIDs := idsBySystem(k.IDs), idsBySystem(other.IDs)
+	for system, values := range ids {
+		otherValues, ok := otherIDs[system]
+		if ok && !slices.ContainsFunc(values, func(id string) bool { return slices.Contains(otherValues, id) }) {
+			diff = append(diff, "035")
+			break
+		}
+	}
+	return diff
+}
+
+// idsBySystem groups control numbers by the system given between parentheses.
+func idsBySystem(ids []string) map[string][]string {
+	systems := make(map[string][]string)
+	for _, id := range ids {
+		system, value := "", id
+		if strings.HasPrefix(id, "(") {
+			if end := strings.IndexByte(id, ')'); end > 0 {
+				system, value = id[1:end], id[end+1:]
+			}
+		}
+		systems[system] = append(systems[system], strings.TrimSpace(value))
+	}
+	return systems
+}
+
+var foldAccents = strings.NewReplacer(
+	"à", "a", "â", "a", "ä", "a", "á", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"î", "i", "ï", "i", "í", "i", "ì", "i",
+	"ô", "o", "ö", "o", "ó", "o", "ò", "o", "õ", "o", "ø", "o",
+	"ù", "u", "û", "u", "ü", "u", "ú", "u",
+	"ç", "c", "ñ", "n", "ÿ", "y", "œ", "oe", "æ", "ae", "ß", "ss",
+)
+
+// normalizeTitle lowercases a title and keeps its letters and digits, without
+// accents, separated by single spaces. Non-sorting markers are removed.
+func normalizeTitle(title string) string {
+	title = foldAccents.Replace(strings.ToLower(title))
+	words := strings.FieldsFunc(title, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c) && !unicode.Is(unicode.Mn, c)
+	})
+	for i, w := range words {
+		words[i] = strings.Map(func(c rune) rune {
+			if unicode.Is(unicode.Mn, c) {
+				return -1
+			}
+			return c
+		}, w)
+	}
+	return strings.Join(words, " ")
+}
+
+// normalizeISBN returns an ISBN as ISBN-13 without hyphens, or an empty
+// string if it is not an ISBN. A qualifier following the number is ignored.
+func normalizeISBN(isbn string) string {
+	isbn = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+	n := isbnPattern.FindString(isbn)
+	switch len(n) {
+	case 10:
+		n = "978" + n[:9]
+		sum := 0
+		for i, c := range n {
+			w := 1
+			if i%2 == 1 {
+				w = 3
+			}
+			sum += w * int(c-'0')
+		}
+		return n + string(rune('0'+(10-sum%10)%10))
+	case 13:
+		return n
+	}
+	return ""
+}
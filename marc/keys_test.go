@@ -0,0 +1,47 @@
+package marc
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	unimarc := Record{
+		Leader: "     nam0 22        450 ",
+		Datafields: []Datafield{
+			{Tag: "010", Subfields: []Subfield{{Code: "a", Value: "2-07-036002-4"}, {Code: "b", Value: "br."}}},
+			{Tag: "035", Subfields: []Subfield{{Code: "a", Value: "(OCoLC)489824"}}},
+			{Tag: "100", Subfields: []Subfield{{Code: "a", Value: "19900101d1972    m  y0frey50      ba"}}},
+			{Tag: "200", Subfields: []Subfield{{Code: "a", Value: "\u0098L'\u009cÉtranger"}, {Code: "f", Value: "Albert Camus"}}},
+		},
+	}
+	marc21 := Record{
+		Leader:        "     cam a22     4a 4500",
+		Controlfields: []Controlfield{{Tag: "008", Value: "900101s19uu    fr            000 1 fre d"}},
+		Datafields: []Datafield{
+			{Tag: "020", Subfields: []Subfield{{Code: "a", Value: "9782070360024 (pbk.)"}}},
+			{Tag: "245", Ind1: "1", Ind2: "3", Subfields: []Subfield{{Code: "a", Value: "L'étranger :"}, {Code: "b", Value: "roman"}}},
+		},
+	}
+
+	k := unimarc.Keys()
+	want := Keys{Title: "l etranger", ISBNs: []string{"9782070360024"}, Date: "1972", IDs: []string{"(OCoLC)489824"}}
+	if k.Title != want.Title || !slices.Equal(k.ISBNs, want.ISBNs) || k.Date != want.Date || !slices.Equal(k.IDs, want.IDs) {
+		t.Errorf("UNIMARC: want %+v, got %+v", want, k)
+	}
+	k21 := marc21.Keys()
+	if k21.Title != "l etranger" || !slices.Equal(k21.ISBNs, want.ISBNs) || k21.Date != "" {
+		t.Errorf("MARC21: unexpected keys %+v", k21)
+	}
+	if diff := k.Differences(k21); diff != nil {
+		t.Errorf("want the same work, got differences %v", diff)
+	}
+
+	other := Keys{Title: "la peste", ISBNs: []string{"9782070360420"}, Date: "1947", IDs: []string{"(OCoLC)1", "(FRBNF)2"}}
+	if diff := k.Differences(other); !slices.Equal(diff, []string{"title", "isbn", "date", "035"}) {
+		t.Errorf("want all the differences, got %v", diff)
+	}
+	if diff := k.Differences(Keys{IDs: []string{"(FRBNF)2"}}); diff != nil {
+		t.Errorf("want no difference for unrelated systems, got %v", diff)
+	}
+}
@@ -0,0 +1,163 @@
+package marc
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Format is the MARC format of a record.
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatUNIMARC
+	FormatMARC21
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatUNIMARC:
+		return "UNIMARC"
+	case FormatMARC21:
+		return "MARC21"
+	}
+	return "unknown"
+}
+
+// Leader gives access to the positions of a record leader. Positions missing
+// from a short leader are blank.
+type Leader string
+
+func (l Leader) at(i int) byte {
+	if i < len(l) {
+		return l[i]
+	}
+	return ' '
+}
+
+// Length returns the record length (00-04), or 0 if it is not a number.
+func (l Leader) Length() int {
+	if len(l) < 5 {
+		return 0
+	}
+	n, _ := strconv.Atoi(string(l[:5]))
+	return n
+}
+
+// Status returns the record status (05): "n" new, "c" corrected, "d" deleted...
+func (l Leader) Status() byte { return l.at(5) }
+
+// Type returns the type of record (06): "a" language material, "l" (UNIMARC)
+// or "m" (MARC21) electronic resource...
+func (l Leader) Type() byte { return l.at(6) }
+
+// BibliographicLevel returns the bibliographic level (07): "m" monograph, "s"
+// serial, "a" analytic...
+func (l Leader) BibliographicLevel() byte { return l.at(7) }
+
+// EncodingLevel returns the encoding level (17).
+func (l Leader) EncodingLevel() byte { return l.at(17) }
+
+// CharacterCoding returns the character coding scheme of MARC21 (09): "a" for
+// UTF-8, blank for MARC-8. It is undefined in UNIMARC.
+func (l Leader) CharacterCoding() byte { return l.at(9) }
+
+// IsDeleted tells if the record status is deleted.
+func (l Leader) IsDeleted() bool { return l.Status() == 'd' }
+
+// GetLeader returns the leader of the record.
+func (r *Record) GetLeader() Leader {
+	return Leader(r.Leader)
+}
+
+// Format guesses the format of the record from its fields: the title is 200 in
+// UNIMARC and 245 in MARC21, else the fixed fields are 100 in UNIMARC and 008
+// in MARC21. SUDOC records have a 008 field, but also a 200 title.
+func (r *Record) Format() Format {
+	has := func(tag string) bool { return len(r.GetField(tag)) > 0 }
+	switch {
+	case has("245"):
+		return FormatMARC21
+	case has("200"), has("100"):
+		return FormatUNIMARC
+	case has("008"):
+		return FormatMARC21
+	}
+	return FormatUnknown
+}
+
+var (
+	unimarcFixed    = MustCompile("100$a")
+	unimarcLanguage = MustCompile("101$a")
+	marc21Fixed     = MustCompile("008")
+)
+
+// FixedFields are the coded data common to UNIMARC 100$a and MARC21 008.
+type FixedFields struct {
+	// EntryDate is the date of entry on file: YYYYMMDD in UNIMARC, YYMMDD in
+	// MARC21.
+	EntryDate string
+	// DateType is the type of publication date, e.g. "d" monograph
+	// published in a single year in UNIMARC, "s" single known date in
+	// MARC21.
+	DateType string
+	Date1    string
+	Date2    string
+	// Language is the language of the resource: MARC21 008/35-37, UNIMARC
+	// first 101$a.
+	Language string
+}
+
+// FixedFields decodes the UNIMARC 100$a or the MARC21 008 field, according to
+// the format of the record. Positions missing from a short field are empty.
+func (r *Record) FixedFields() FixedFields {
+	var ff FixedFields
+	switch r.Format() {
+	case FormatUNIMARC:
+		data := unimarcFixed.Value(r)
+		ff = FixedFields{
+			EntryDate: substr(data, 0, 8),
+			DateType:  substr(data, 8, 9),
+			Date1:     substr(data, 9, 13),
+			Date2:     substr(data, 13, 17),
+			Language:  unimarcLanguage.Value(r),
+		}
+	case FormatMARC21:
+		data := marc21Fixed.Value(r)
+		ff = FixedFields{
+			EntryDate: substr(data, 0, 6),
+			DateType:  substr(data, 6, 7),
+			Date1:     substr(data, 7, 11),
+			Date2:     substr(data, 11, 15),
+			Language:  substr(data, 35, 38),
+		}
+	}
+	ff.Date2 = strings.TrimSpace(ff.Date2)
+	return ff
+}
+
+// IsElectronic tells if the record describes an electronic resource: type of
+// record "l" in UNIMARC, "m" in MARC21, or MARC21 008 form of item "o" or "s"
+// for language material.
+func (r *Record) IsElectronic() bool {
+	l := r.GetLeader()
+	switch r.Format() {
+	case FormatUNIMARC:
+		return l.Type() == 'l'
+	case FormatMARC21:
+		if l.Type() == 'm' {
+			return true
+		}
+		form := substr(marc21Fixed.Value(r), 23, 24)
+		return (l.Type() == 'a' || l.Type() == 't') && (form == "o" || form == "s")
+	}
+	return false
+}
+
+// substr returns s[from:to], truncated to the length of s.
+func substr(s string, from, to int) string {
+	if from >= len(s) {
+		return ""
+	}
+	return s[from:min(to, len(s))]
+}
@@ -0,0 +1,61 @@
+package marc
+
+import "testing"
+
+func TestLeaderPositions(t *testing.T) {
+	l := Leader("01234dam0 22        450 ")
+	if l.Length() != 1234 || l.Status() != 'd' || !l.IsDeleted() || l.Type() != 'a' ||
+		l.BibliographicLevel() != 'm' || l.EncodingLevel() != ' ' {
+		t.Errorf("unexpected leader positions for %q", l)
+	}
+	if short := Leader("00"); short.Length() != 0 || short.Status() != ' ' {
+		t.Error("short leader: want blank positions")
+	}
+}
+
+func TestFixedFields(t *testing.T) {
+	unimarc := Record{
+		Leader:        "     nlm0 22        450 ",
+		Controlfields: []Controlfield{{Tag: "008", Value: "Oax3"}},
+		Datafields: []Datafield{
+			{Tag: "100", Subfields: []Subfield{{Code: "a", Value: "20130626d1928    m  y0frey50      ba"}}},
+			{Tag: "101", Subfields: []Subfield{{Code: "a", Value: "fre"}}},
+			{Tag: "200", Subfields: []Subfield{{Code: "a", Value: "Orlando"}}},
+		},
+	}
+	marc21 := Record{
+		Leader:        "     cam a22     4a 4500",
+		Controlfields: []Controlfield{{Tag: "008", Value: "130626s1928    xxk     o     000 1 eng d"}},
+		Datafields:    []Datafield{{Tag: "245", Subfields: []Subfield{{Code: "a", Value: "Orlando"}}}},
+	}
+
+	tests := []struct {
+		record     *Record
+		format     Format
+		ff         FixedFields
+		electronic bool
+	}{
+		{&unimarc, FormatUNIMARC, FixedFields{"20130626", "d", "1928", "", "fre"}, true},
+		{&marc21, FormatMARC21, FixedFields{"130626", "s", "1928", "", "eng"}, true},
+		{&Record{}, FormatUnknown, FixedFields{}, false},
+	}
+	for _, test := range tests {
+		if got := test.record.Format(); got != test.format {
+			t.Errorf("Format: want %s, got %s", test.format, got)
+		}
+		if got := test.record.FixedFields(); got != test.ff {
+			t.Errorf("%s: want %+v, got %+v", test.format, test.ff, got)
+		}
+		if got := test.record.IsElectronic(); got != test.electronic {
+			t.Errorf("%s: IsElectronic: want %t, got %t", test.format, test.electronic, got)
+		}
+	}
+
+	sudoc, err := NewRecord(correctXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sudoc.Format() != FormatUNIMARC || sudoc.IsElectronic() {
+		t.Errorf("SUDOC record: want UNIMARC printed resource")
+	}
+}
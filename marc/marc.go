@@ -1,10 +1,11 @@
-// Package marcxml provides some functions to extract informations from
-// a MARCXML record.
+// Package marc provides some functions to extract informations from
+// MARCXML and ISO 2709 records.
 package marc
 
 import (
 	"encoding/xml"
 	"errors"
+	"fmt"
 )
 
 type Record struct {
@@ -34,6 +35,12 @@ type Subfield struct {
 	Value   string   `xml:",chardata"`
 }
 
+// Collection is a MARCXML document containing several records.
+type Collection struct {
+	XMLName xml.Name  `xml:"collection"`
+	Records []*Record `xml:"record"`
+}
+
 // Discriminated union type. A Field includes Controlfield and Datafield.
 type Field interface {
 	GetValue(code string) []string
@@ -54,6 +61,26 @@ func NewRecord(xmlData []byte) (*Record, error) {
 	return &r, nil
 }
 
+// NewRecords converts xml data into Records. The data is either a
+// <collection> of records or a single <record>. Use an XMLReader for large
+// documents.
+func NewRecords(xmlData []byte) ([]*Record, error) {
+	if xmlData == nil {
+		return nil, errors.New("NewRecords: can't process nil data")
+	}
+	var c Collection
+	err := xml.Unmarshal(xmlData, &c)
+	if err == nil {
+		return c.Records, nil
+	}
+	// Not a collection: try a single record.
+	r, err2 := NewRecord(xmlData)
+	if err2 != nil {
+		return nil, fmt.Errorf("NewRecords: %w", err)
+	}
+	return []*Record{r}, nil
+}
+
 // Indicators returns a list of pairs of indicators for a given tag. One entry
 // of the list corresponds to a repeated field. The list is nil if the field is
 // a controlfield.
@@ -0,0 +1,86 @@
+package marc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupportedCharset is returned when MARC-8 data switches to a character
+// set other than Basic and Extended Latin (ANSEL).
+var ErrUnsupportedCharset = errors.New("unsupported MARC-8 character set")
+
+// ansel maps the Extended Latin (ANSEL) characters of MARC-8 to Unicode.
+var ansel = map[byte]rune{
+	0xA1: 'Ł', 0xA2: 'Ø', 0xA3: 'Đ', 0xA4: 'Þ', 0xA5: 'Æ', 0xA6: 'Œ', 0xA7: 'ʹ',
+	0xA8: '·', 0xA9: '♭', 0xAA: '®', 0xAB: '±', 0xAC: 'Ơ', 0xAD: 'Ư', 0xAE: 'ʼ',
+	0xB0: 'ʻ', 0xB1: 'ł', 0xB2: 'ø', 0xB3: 'đ', 0xB4: 'þ', 0xB5: 'æ', 0xB6: 'œ',
+	0xB7: 'ʺ', 0xB8: 'ı', 0xB9: '£', 0xBA: 'ð', 0xBC: 'ơ', 0xBD: 'ư',
+	0xC0: '°', 0xC1: 'ℓ', 0xC2: '℗', 0xC3: '©', 0xC4: '♯', 0xC5: '¿', 0xC6: '¡',
+	0xC7: 'ß', 0xC8: '€',
+}
+
+// anselCombining maps the ANSEL combining diacritics to Unicode. In MARC-8
+// they precede the base character, in Unicode they follow it.
+var anselCombining = map[byte]rune{
+	0xE0: '\u0309', 0xE1: '\u0300', 0xE2: '\u0301', 0xE3: '\u0302', 0xE4: '\u0303',
+	0xE5: '\u0304', 0xE6: '\u0306', 0xE7: '\u0307', 0xE8: '\u0308', 0xE9: '\u030C',
+	0xEA: '\u030A', 0xEB: '\uFE20', 0xEC: '\uFE21', 0xED: '\u0315', 0xEE: '\u030B',
+	0xEF: '\u0310', 0xF0: '\u0327', 0xF1: '\u0328', 0xF2: '\u0323', 0xF3: '\u0324',
+	0xF4: '\u0325', 0xF5: '\u0333', 0xF6: '\u0332', 0xF7: '\u0326', 0xF8: '\u031C',
+	0xF9: '\u032E', 0xFA: '\uFE22', 0xFB: '\uFE23', 0xFE: '\u0313',
+}
+
+// DecodeMARC8 converts MARC-8 data to UTF-8. Only the default character sets,
+// Basic Latin (ASCII) and Extended Latin (ANSEL), are supported: escape
+// sequences designating them are accepted, any other one is an error.
+// Combining diacritics are moved after their base character; the result is not
+// normalized.
+func DecodeMARC8(data []byte) (string, error) {
+	var sb strings.Builder
+	var pending []rune
+	emit := func(r rune) {
+		sb.WriteRune(r)
+		for _, c := range pending {
+			sb.WriteRune(c)
+		}
+		pending = pending[:0]
+	}
+
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b == 0x1B:
+			n, err := marc8Escape(data[i:])
+			if err != nil {
+				return sb.String(), fmt.Errorf("DecodeMARC8: offset %d: %w", i, err)
+			}
+			i += n - 1
+		case b < 0x80:
+			emit(rune(b))
+		case anselCombining[b] != 0:
+			pending = append(pending, anselCombining[b])
+		case ansel[b] != 0:
+			emit(ansel[b])
+		default:
+			return sb.String(), fmt.Errorf("DecodeMARC8: offset %d: invalid byte 0x%02X", i, b)
+		}
+	}
+	// Diacritics without base character.
+	for _, c := range pending {
+		sb.WriteRune(c)
+	}
+	return sb.String(), nil
+}
+
+// marc8Escape checks the escape sequence at the start of data and returns its
+// length.
+func marc8Escape(data []byte) (int, error) {
+	for _, seq := range []string{"\x1b(B", "\x1b)!E", "\x1b)E", "\x1bs"} {
+		if strings.HasPrefix(string(data), seq) {
+			return len(seq), nil
+		}
+	}
+	end := min(len(data), 4)
+	return 0, fmt.Errorf("%w: escape sequence %q", ErrUnsupportedCharset, data[:end])
+}
@@ -254,3 +254,35 @@ func equalInd(i1, i2 [][2]string) bool {
 	}
 	return true
 }
+
+func TestNewRecords(t *testing.T) {
+	collection := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<marc:collection xmlns:marc="http://www.loc.gov/MARC21/slim">
+  <marc:record>
+    <marc:leader>     cam0 22        450 </marc:leader>
+    <marc:controlfield tag="001">123456789</marc:controlfield>
+  </marc:record>
+  <marc:record>
+    <marc:controlfield tag="001">12345678X</marc:controlfield>
+  </marc:record>
+</marc:collection>`)
+	records, err := NewRecords(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Controlfields[0].Value != "12345678X" {
+		t.Errorf("unexpected records %+v", records)
+	}
+
+	records, err = NewRecords(correctXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(records[0].Datafields) != 10 {
+		t.Errorf("single record: unexpected records %+v", records)
+	}
+
+	if _, err := NewRecords(errorXML); err == nil {
+		t.Error("want error")
+	}
+}
@@ -0,0 +1,390 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RecordError reports a malformed record of a MARCXML document. The record is
+// skipped: reading can go on with the next one.
+type RecordError struct {
+	// Record is the position of the record in the document, from 1.
+	Record int
+	// Line is the line where the error was found.
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d, line %d: %v", e.Record, e.Line, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// XMLReader reads the records of a MARCXML document one at a time, with or
+// without namespace, in constant memory. The document is a <collection> or a
+// single <record>.
+type XMLReader struct {
+	br  *bufio.Reader
+	dec *xml.Decoder
+	// n is the number of records found.
+	n int
+	// line is the number of lines before the start of the current decoder.
+	line int
+	// resynced is set once the decoder was restarted after a syntax error.
+	resynced bool
+	err      error
+}
+
+// NewXMLReader returns a reader of the records of r.
+func NewXMLReader(r io.Reader) *XMLReader {
+	// xml.Decoder does not buffer an io.ByteReader: after a syntax error,
+	// reading can go on from where the decoder stopped.
+	xr := XMLReader{br: bufio.NewReader(r)}
+	xr.dec = xml.NewDecoder(xr.br)
+	return &xr
+}
+
+// Read returns the next record, or io.EOF when there is no more record. A
+// malformed record is reported by a *RecordError, and the next call to Read
+// returns the following record. Any other error is final.
+func (xr *XMLReader) Read() (*Record, error) {
+	if xr.err != nil {
+		return nil, xr.err
+	}
+	for {
+		tok, err := xr.dec.Token()
+		if err == io.EOF {
+			xr.err = io.EOF
+			return nil, io.EOF
+		}
+		if err != nil {
+			// Syntax error outside of a record, such as the end of the
+			// collection after a restart: look for the next record.
+			if !xr.resynced {
+				xr.err = fmt.Errorf("Read: line %d: %w", xr.inputLine(), err)
+				return nil, xr.err
+			}
+			if err := xr.resync(); err != nil {
+				xr.err = err
+				return nil, err
+			}
+			continue
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "record" {
+			xr.n++
+			return xr.readRecord()
+		}
+	}
+}
+
+// ReadAll returns all the remaining records. Malformed records are skipped and
+// their errors returned together.
+func (xr *XMLReader) ReadAll() ([]*Record, error) {
+	var records []*Record
+	var errs []error
+	for {
+		record, err := xr.Read()
+		var recordErr *RecordError
+		switch {
+		case err == io.EOF:
+			return records, errors.Join(errs...)
+		case errors.As(err, &recordErr):
+			errs = append(errs, err)
+		case err != nil:
+			return records, errors.Join(append(errs, err)...)
+		default:
+			records = append(records, record)
+		}
+	}
+}
+
+// readRecord reads the content of a <record> element, up to its end.
+func (xr *XMLReader) readRecord() (*Record, error) {
+	var record Record
+	var recordErr error
+	fail := func(err error) {
+		if recordErr == nil {
+			recordErr = &RecordError{Record: xr.n, Line: xr.inputLine(), Err: err}
+		}
+	}
+
+	for depth := 1; depth > 0; {
+		tok, err := xr.dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			fail(err)
+			if err := xr.resync(); err != nil && err != io.EOF {
+				xr.err = err
+			}
+			return nil, recordErr
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if recordErr != nil || depth > 1 {
+				depth++
+				continue
+			}
+			if err := xr.readElement(&record, tok); err != nil {
+				fail(err)
+				if _, ok := err.(*xml.SyntaxError); ok {
+					if err := xr.resync(); err != nil && err != io.EOF {
+						xr.err = err
+					}
+					return nil, recordErr
+				}
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if recordErr != nil {
+		return nil, recordErr
+	}
+	return &record, nil
+}
+
+// readElement reads a child element of a record, up to its end.
+func (xr *XMLReader) readElement(record *Record, start xml.StartElement) error {
+	switch start.Name.Local {
+	case "leader":
+		text, err := xr.readText()
+		record.Leader = text
+		return err
+	case "controlfield":
+		text, err := xr.readText()
+		if err != nil {
+			return err
+		}
+		tag := attr(start, "tag")
+		if len(tag) != 3 {
+			return fmt.Errorf("controlfield: invalid tag %q", tag)
+		}
+		record.Controlfields = append(record.Controlfields, Controlfield{Tag: tag, Value: text})
+		return nil
+	case "datafield":
+		df := Datafield{Tag: attr(start, "tag"), Ind1: attr(start, "ind1"), Ind2: attr(start, "ind2")}
+		if err := xr.readSubfields(&df); err != nil {
+			return err
+		}
+		if len(df.Tag) != 3 {
+			return fmt.Errorf("datafield: invalid tag %q", df.Tag)
+		}
+		record.Datafields = append(record.Datafields, df)
+		return nil
+	default:
+		if err := xr.dec.Skip(); err != nil {
+			return err
+		}
+		return fmt.Errorf("unexpected element <%s>", start.Name.Local)
+	}
+}
+
+// readSubfields reads the subfields of a datafield, up to its end.
+func (xr *XMLReader) readSubfields(df *Datafield) error {
+	for {
+		tok, err := xr.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if tok.Name.Local != "subfield" {
+				if err := xr.dec.Skip(); err != nil {
+					return err
+				}
+				return fmt.Errorf("datafield %s: unexpected element <%s>", df.Tag, tok.Name.Local)
+			}
+			text, err := xr.readText()
+			if err != nil {
+				return err
+			}
+			code := attr(tok, "code")
+			if len(code) != 1 {
+				return fmt.Errorf("datafield %s: invalid subfield code %q", df.Tag, code)
+			}
+			df.Subfields = append(df.Subfields, Subfield{Code: code, Value: text})
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// readText returns the text content of an element, up to its end.
+func (xr *XMLReader) readText() (string, error) {
+	var sb strings.Builder
+	for {
+		tok, err := xr.dec.Token()
+		if err != nil {
+			return sb.String(), err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			sb.Write(tok)
+		case xml.StartElement:
+			if err := xr.dec.Skip(); err != nil {
+				return sb.String(), err
+			}
+			return sb.String(), fmt.Errorf("unexpected element <%s>", tok.Name.Local)
+		case xml.EndElement:
+			return sb.String(), nil
+		}
+	}
+}
+
+func attr(start xml.StartElement, name string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// inputLine returns the current line in the document.
+func (xr *XMLReader) inputLine() int {
+	line, _ := xr.dec.InputPos()
+	return xr.line + line
+}
+
+// resync skips the data up to the start of the next record and restarts the
+// decoder there. It returns io.EOF if there is no more record.
+func (xr *XMLReader) resync() error {
+	xr.line = xr.inputLine() - 1
+	xr.resynced = true
+	for {
+		next, _ := xr.br.Peek(64)
+		if len(next) == 0 {
+			xr.err = io.EOF
+			return io.EOF
+		}
+		if next[0] == '<' {
+			name := string(next[1:])
+			if end := strings.IndexAny(name, " \t\r\n/>"); end > 0 {
+				name = name[:end]
+				if _, local, ok := strings.Cut(name, ":"); ok {
+					name = local
+				}
+				if name == "record" {
+					xr.dec = xml.NewDecoder(xr.br)
+					return nil
+				}
+			}
+		}
+		b, err := xr.br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == '\n' {
+			xr.line++
+		}
+	}
+}
+
+// Namespace is the MARCXML namespace.
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+// MarshalXML encodes a single record in MARCXML, as a namespaced <record>
+// document.
+func MarshalXML(r *Record) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := writeXMLRecord(&buf, r, ` xmlns="`+Namespace+`"`, ""); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// XMLWriter writes records in a MARCXML <collection>. Close must be called to
+// end the document.
+type XMLWriter struct {
+	w       io.Writer
+	started bool
+}
+
+// NewXMLWriter returns a writer of a MARCXML collection to w.
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	return &XMLWriter{w: w}
+}
+
+// Write writes a record of the collection.
+func (xw *XMLWriter) Write(r *Record) error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := writeXMLRecord(&buf, r, "", "  "); err != nil {
+		return err
+	}
+	_, err := xw.w.Write(buf.Bytes())
+	return err
+}
+
+// Close ends the collection. It does not close the underlying writer.
+func (xw *XMLWriter) Close() error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(xw.w, "</collection>\n")
+	return err
+}
+
+func (xw *XMLWriter) start() error {
+	if xw.started {
+		return nil
+	}
+	xw.started = true
+	_, err := io.WriteString(xw.w, xml.Header+`<collection xmlns="`+Namespace+`">`+"\n")
+	return err
+}
+
+// writeXMLRecord writes a <record> element, with the given attributes and
+// indentation.
+func writeXMLRecord(buf *bytes.Buffer, r *Record, attrs, indent string) error {
+	text := func(s string) {
+		xml.EscapeText(buf, []byte(s))
+	}
+	fmt.Fprintf(buf, "%s<record%s>\n", indent, attrs)
+	if r.Leader != "" {
+		fmt.Fprintf(buf, "%s  <leader>", indent)
+		text(r.Leader)
+		buf.WriteString("</leader>\n")
+	}
+	for _, cf := range r.Controlfields {
+		if len(cf.Tag) != 3 {
+			return fmt.Errorf("MarshalXML: invalid tag %q", cf.Tag)
+		}
+		fmt.Fprintf(buf, "%s  <controlfield tag=\"%s\">", indent, cf.Tag)
+		text(cf.Value)
+		buf.WriteString("</controlfield>\n")
+	}
+	for _, df := range r.Datafields {
+		if len(df.Tag) != 3 {
+			return fmt.Errorf("MarshalXML: invalid tag %q", df.Tag)
+		}
+		fmt.Fprintf(buf, "%s  <datafield tag=\"%s\" ind1=\"", indent, df.Tag)
+		text(indicator(df.Ind1))
+		buf.WriteString(`" ind2="`)
+		text(indicator(df.Ind2))
+		buf.WriteString("\">\n")
+		for _, sub := range df.Subfields {
+			fmt.Fprintf(buf, "%s    <subfield code=\"", indent)
+			text(sub.Code)
+			buf.WriteString(`">`)
+			text(sub.Value)
+			buf.WriteString("</subfield>\n")
+		}
+		fmt.Fprintf(buf, "%s  </datafield>\n", indent)
+	}
+	fmt.Fprintf(buf, "%s</record>\n", indent)
+	return nil
+}
@@ -0,0 +1,128 @@
+package marc
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const streamXML = `<?xml version="1.0" encoding="UTF-8"?>
+<marc:collection xmlns:marc="http://www.loc.gov/MARC21/slim">
+  <marc:record>
+    <marc:leader>     cam0 22        450 </marc:leader>
+    <marc:controlfield tag="001">111111111</marc:controlfield>
+    <marc:datafield tag="200" ind1="1" ind2=" ">
+      <marc:subfield code="a">Orlando &amp; co</marc:subfield>
+    </marc:datafield>
+  </marc:record>
+  <marc:record>
+    <marc:controlfield tag="1">222222222</marc:controlfield>
+  </marc:record>
+  <marc:record>
+    <marc:controlfield tag="001">333333333</marc:controlfield>
+    <marc:datafield tag="200" ind1="1" ind2=" ">
+      <marc:subfield code="a">Non fermé</marc:subfieldx>
+    </marc:datafield>
+  </marc:record>
+  <marc:record>
+    <marc:controlfield tag="001">444444444</marc:controlfield>
+  </marc:record>
+</marc:collection>`
+
+func TestXMLReader(t *testing.T) {
+	r := NewXMLReader(strings.NewReader(streamXML))
+
+	record, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Leader != "     cam0 22        450 " || record.Controlfields[0].Value != "111111111" ||
+		record.Datafields[0].Subfields[0].Value != "Orlando & co" {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	// Invalid tag.
+	var recordErr *RecordError
+	if _, err := r.Read(); !errors.As(err, &recordErr) || recordErr.Record != 2 || recordErr.Line != 11 {
+		t.Errorf("want error on record 2, line 11, got %v", err)
+	}
+	// Syntax error.
+	if _, err := r.Read(); !errors.As(err, &recordErr) || recordErr.Record != 3 || recordErr.Line != 16 {
+		t.Errorf("want error on record 3, line 16, got %v", err)
+	}
+
+	record, err = r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Controlfields[0].Value != "444444444" {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("want io.EOF, got %v", err)
+	}
+}
+
+func TestXMLReaderReadAll(t *testing.T) {
+	records, err := NewXMLReader(strings.NewReader(streamXML)).ReadAll()
+	if len(records) != 2 || err == nil {
+		t.Errorf("want 2 records and an error, got %d, %v", len(records), err)
+	}
+
+	records, err = NewXMLReader(strings.NewReader(string(correctXML))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(records[0].Datafields) != 10 {
+		t.Errorf("single record: unexpected records %+v", records)
+	}
+
+	if _, err := NewXMLReader(strings.NewReader("<collection><</collection>")).ReadAll(); err == nil {
+		t.Error("want error on a malformed document")
+	}
+}
+
+func TestXMLWriter(t *testing.T) {
+	records, err := NewXMLReader(strings.NewReader(string(correctXML))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	records[0].Datafields[1].Subfields[0].Value = `Orlando <"&">`
+
+	var buf strings.Builder
+	w := NewXMLWriter(&buf)
+	for i := 0; i < 2; i++ {
+		if err := w.Write(records[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<collection xmlns="http://www.loc.gov/MARC21/slim">`) {
+		t.Errorf("missing namespaced collection:\n%s", buf.String())
+	}
+
+	got, err := NewXMLReader(strings.NewReader(buf.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Leader != records[0].Leader ||
+		len(got[1].Controlfields) != 3 || len(got[1].Datafields) != 10 ||
+		got[1].Datafields[1].Subfields[0].Value != `Orlando <"&">` {
+		t.Errorf("round trip: unexpected records %+v", got)
+	}
+
+	single, err := MarshalXML(records[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := NewRecord(single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Datafields) != 10 || record.XMLName.Space != Namespace {
+		t.Errorf("single record: unexpected record %+v", record)
+	}
+}
@@ -0,0 +1,35 @@
+package marc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MarshalMRK encodes a record in the mnemonic line format of MarcEdit (.mrk):
+// one line per field, "=TAG  " followed by the value, blank indicators as "\",
+// "$" as subfield delimiter and "{dollar}" for a "$" in the data. Records are
+// separated by a blank line.
+func MarshalMRK(r *Record) string {
+	var sb strings.Builder
+	escape := strings.NewReplacer("$", "{dollar}", "\n", " ", "\r", " ")
+	fmt.Fprintf(&sb, "=LDR  %s\n", strings.ReplaceAll(escape.Replace(r.Leader), " ", `\`))
+	for _, cf := range r.Controlfields {
+		fmt.Fprintf(&sb, "=%s  %s\n", cf.Tag, strings.ReplaceAll(escape.Replace(cf.Value), " ", `\`))
+	}
+	for _, df := range r.Datafields {
+		fmt.Fprintf(&sb, "=%s  %s%s", df.Tag, mrkIndicator(df.Ind1), mrkIndicator(df.Ind2))
+		for _, sub := range df.Subfields {
+			fmt.Fprintf(&sb, "$%s%s", sub.Code, escape.Replace(sub.Value))
+		}
+		sb.WriteByte('\n')
+	}
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+func mrkIndicator(ind string) string {
+	if ind = indicator(ind); ind == " " {
+		return `\`
+	}
+	return ind
+}
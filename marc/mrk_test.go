@@ -0,0 +1,22 @@
+package marc
+
+import "testing"
+
+func TestMarshalMRK(t *testing.T) {
+	record := Record{
+		Leader:        "     nam  22        450 ",
+		Controlfields: []Controlfield{{Tag: "001", Value: "123456789"}, {Tag: "008", Value: "a b"}},
+		Datafields: []Datafield{
+			{Tag: "200", Ind1: "1", Subfields: []Subfield{{Code: "a", Value: "Prix : 10 $"}, {Code: "f", Value: "Virginia Woolf"}}},
+		},
+	}
+	want := `=LDR  \\\\\nam\\22\\\\\\\\450\
+=001  123456789
+=008  a\b
+=200  1\$aPrix : 10 {dollar}$fVirginia Woolf
+
+`
+	if got := MarshalMRK(&record); got != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+}
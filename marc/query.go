@@ -0,0 +1,355 @@
+package marc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Query is a compiled path to values of a record. Its syntax is:
+//
+//	TAG [PREDICATE]... [$CODES] [/POSITIONS]
+//
+// TAG is three characters, where X matches any digit ("7XX"), or LDR for the
+// leader. Each PREDICATE, between brackets, must hold for a field to match:
+//
+//	[$5]               subfield 5 is present
+//	[$5=ETAB1]         a subfield 5 equals ETAB1 (also !=, ^= prefix,
+//	                   $= suffix, *= contains, ~= regular expression)
+//	[ind1=1]           first indicator is 1, [ind2=" "] second is blank
+//
+// Values may be quoted with double quotes. $CODES selects the values of one or
+// more subfields ("930$a", "200$ae"); without it, the value of a control field
+// or the subfield values of a data field joined by spaces are selected.
+// POSITIONS extracts characters, from 0: "008/07" or "008/07-10" (inclusive).
+//
+// Examples: "930$5", "930[$5^=200000001]$c", "7XX$a", "008/07-10",
+// "702[ind2=1]$3".
+type Query struct {
+	src        string
+	tag        string
+	predicates []predicate
+	codes      string
+	from, to   int
+}
+
+type predicate struct {
+	// code is the subfield code, or "1" or "2" for indicators when
+	// indicator is set.
+	code      string
+	indicator bool
+	op        string
+	value     string
+	re        *regexp.Regexp
+}
+
+// QueryError reports a syntax error in a query.
+type QueryError struct {
+	Query string
+	// Pos is the position of the error in the query.
+	Pos int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("query %q: position %d: %s", e.Query, e.Pos, e.Msg)
+}
+
+var queryOperators = []string{"!=", "^=", "$=", "*=", "~=", "="}
+
+// Compile parses a query.
+func Compile(src string) (*Query, error) {
+	q := Query{src: src, from: -1, to: -1}
+	p := queryParser{src: src}
+	fail := func(msg string, args ...any) (*Query, error) {
+		return nil, &QueryError{Query: src, Pos: p.pos, Msg: fmt.Sprintf(msg, args...)}
+	}
+
+	if len(src) < 3 {
+		return fail("missing tag")
+	}
+	q.tag = strings.ToUpper(src[:3])
+	if q.tag != "LDR" {
+		for _, c := range q.tag {
+			if (c < '0' || c > '9') && c != 'X' {
+				return fail("invalid tag %q", src[:3])
+			}
+		}
+	}
+	p.pos = 3
+
+	for p.peek() == '[' {
+		p.pos++
+		pred, err := p.predicate()
+		if err != "" {
+			return fail("%s", err)
+		}
+		if pred.op == "~=" {
+			re, rerr := regexp.Compile(pred.value)
+			if rerr != nil {
+				return fail("%v", rerr)
+			}
+			pred.re = re
+		}
+		if p.peek() != ']' {
+			return fail("missing ]")
+		}
+		p.pos++
+		q.predicates = append(q.predicates, pred)
+	}
+
+	if p.peek() == '$' {
+		p.pos++
+		start := p.pos
+		for p.pos < len(src) && src[p.pos] != '/' {
+			p.pos++
+		}
+		q.codes = src[start:p.pos]
+		if q.codes == "" {
+			return fail("missing subfield code")
+		}
+	}
+
+	if p.peek() == '/' {
+		p.pos++
+		from, to, _ := strings.Cut(src[p.pos:], "-")
+		var err1, err2 error
+		q.from, err1 = strconv.Atoi(from)
+		q.to = q.from
+		if to != "" {
+			q.to, err2 = strconv.Atoi(to)
+		}
+		if err1 != nil || err2 != nil || q.from < 0 || q.to < q.from {
+			return fail("invalid positions %q", src[p.pos:])
+		}
+		p.pos = len(src)
+	}
+
+	if p.pos != len(src) {
+		return fail("unexpected %q", src[p.pos:])
+	}
+	return &q, nil
+}
+
+// MustCompile is like Compile but panics on error. It is meant for queries
+// which are constants.
+func MustCompile(src string) *Query {
+	q, err := Compile(src)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+type queryParser struct {
+	src string
+	pos int
+}
+
+func (p *queryParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// predicate parses the content of a predicate, up to the closing bracket. It
+// returns an error message.
+func (p *queryParser) predicate() (predicate, string) {
+	var pred predicate
+	rest := p.src[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "$") && len(rest) > 1:
+		pred.code = rest[1:2]
+		p.pos += 2
+	case strings.HasPrefix(rest, "ind1"), strings.HasPrefix(rest, "ind2"):
+		pred.code = rest[3:4]
+		pred.indicator = true
+		p.pos += 4
+	default:
+		return pred, "predicate must start with $ or ind"
+	}
+
+	if p.peek() == ']' {
+		if pred.indicator {
+			return pred, "missing indicator value"
+		}
+		return pred, ""
+	}
+	for _, op := range queryOperators {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			pred.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if pred.op == "" {
+		return pred, "missing operator"
+	}
+
+	if p.peek() == '"' {
+		end := strings.IndexByte(p.src[p.pos+1:], '"')
+		if end < 0 {
+			return pred, "unterminated string"
+		}
+		pred.value = p.src[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+	} else {
+		end := strings.IndexByte(p.src[p.pos:], ']')
+		if end < 0 {
+			return pred, "missing ]"
+		}
+		pred.value = p.src[p.pos : p.pos+end]
+		p.pos += end
+	}
+	if pred.indicator && pred.op != "=" && pred.op != "!=" {
+		return pred, "indicators only support = and !="
+	}
+	return pred, ""
+}
+
+// String returns the source of the query.
+func (q *Query) String() string {
+	return q.src
+}
+
+// matchTag tells if the tag matches the pattern of the query.
+func (q *Query) matchTag(tag string) bool {
+	if len(tag) != 3 {
+		return false
+	}
+	for i := 0; i < 3; i++ {
+		if q.tag[i] != 'X' && q.tag[i] != tag[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Fields returns the fields of the record matching the tag and the
+// predicates of the query, control fields first. The leader is returned as a
+// control field of tag LDR. The other fields are those of the record, not
+// copies.
+func (q *Query) Fields(r *Record) []Field {
+	var res []Field
+	if q.tag == "LDR" {
+		if len(q.predicates) == 0 {
+			res = append(res, &Controlfield{Tag: "LDR", Value: r.Leader})
+		}
+		return res
+	}
+	for i := range r.Controlfields {
+		if q.matchTag(r.Controlfields[i].Tag) && len(q.predicates) == 0 {
+			res = append(res, &r.Controlfields[i])
+		}
+	}
+	for i := range r.Datafields {
+		if df := &r.Datafields[i]; q.matchTag(df.Tag) && q.matchPredicates(df) {
+			res = append(res, df)
+		}
+	}
+	return res
+}
+
+func (q *Query) matchPredicates(df *Datafield) bool {
+	for _, pred := range q.predicates {
+		if !pred.match(df) {
+			return false
+		}
+	}
+	return true
+}
+
+func (pred predicate) match(df *Datafield) bool {
+	if pred.indicator {
+		ind := df.Ind1
+		if pred.code == "2" {
+			ind = df.Ind2
+		}
+		return (blankIndicator(ind) == pred.value) == (pred.op == "=")
+	}
+	values := df.GetValue(pred.code)
+	if pred.op == "" {
+		return len(values) > 0
+	}
+	if pred.op == "!=" {
+		for _, v := range values {
+			if v == pred.value {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		if pred.test(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (pred predicate) test(v string) bool {
+	switch pred.op {
+	case "=":
+		return v == pred.value
+	case "^=":
+		return strings.HasPrefix(v, pred.value)
+	case "$=":
+		return strings.HasSuffix(v, pred.value)
+	case "*=":
+		return strings.Contains(v, pred.value)
+	case "~=":
+		return pred.re.MatchString(v)
+	}
+	return false
+}
+
+// Values returns the values selected by the query in the record, in field
+// order. Values shorter than the requested positions are truncated, or
+// skipped if they do not reach the first position.
+func (q *Query) Values(r *Record) []string {
+	var res []string
+	add := func(v string) {
+		if q.from >= 0 {
+			runes := []rune(v)
+			if q.from >= len(runes) {
+				return
+			}
+			v = string(runes[q.from:min(q.to+1, len(runes))])
+		}
+		res = append(res, v)
+	}
+
+	for _, field := range q.Fields(r) {
+		switch f := field.(type) {
+		case *Controlfield:
+			if q.codes == "" {
+				add(f.Value)
+			}
+		case *Datafield:
+			if q.codes == "" {
+				values := make([]string, len(f.Subfields))
+				for i, sub := range f.Subfields {
+					values[i] = sub.Value
+				}
+				add(strings.Join(values, " "))
+				continue
+			}
+			for _, sub := range f.Subfields {
+				if strings.Contains(q.codes, sub.Code) {
+					add(sub.Value)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// Value returns the first value selected by the query, or an empty string.
+func (q *Query) Value(r *Record) string {
+	if values := q.Values(r); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
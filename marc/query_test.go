@@ -0,0 +1,66 @@
+package marc
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	record, err := NewRecord(correctXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The SUDOC writes blank indicators as "#".
+	record.Datafields = append(record.Datafields, Datafield{Tag: "930", Ind1: "#", Ind2: "#",
+		Subfields: []Subfield{{Code: "5", Value: "200000001:EX2"}, {Code: "c", Value: "Magasin"}}})
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"930$5", []string{"ETAB1", "ETAB3", "200000001:EX2"}},
+		{"930[$5^=200000001]$c", []string{"Magasin"}},
+		{"930[$5=ETAB1]$ca", []string{"Libre-accès", "8 WOO"}},
+		{"930[$c]$5", []string{"ETAB1", "200000001:EX2"}},
+		{"930[$5!=ETAB1][$5!=ETAB3]$5", []string{"200000001:EX2"}},
+		{`930[$5~="^ETAB[0-9]$"]$5`, []string{"ETAB1", "ETAB3"}},
+		{"930[ind1=2]$5", []string{"ETAB1"}},
+		{`930[ind1=" "]$5`, []string{"ETAB3", "200000001:EX2"}},
+		{"7XX$a", []string{"Pappo-Musard", "Nordon"}},
+		{"702[ind2=1][$4]$3", []string{"02704324X"}},
+		{"200", []string{"Orlando Virginia Woolf trad de Catherine Pappo"}},
+		{"008", []string{"Aax3", "Oay3"}},
+		{"008/1-2", []string{"ax", "ay"}},
+		{"008/3-10", []string{"3", "3"}},
+		{"008/5", nil},
+		{"LDR/05-07", []string{"cam"}},
+		{"940$a/0-3", []string{"2013", "2011"}},
+		{"999$a", nil},
+	}
+	for _, test := range tests {
+		q, err := Compile(test.query)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", test.query, err)
+			continue
+		}
+		if got := q.Values(record); !slices.Equal(got, test.want) {
+			t.Errorf("%s: want %q, got %q", test.query, test.want, got)
+		}
+	}
+
+	if got := MustCompile("930$5").Value(record); got != "ETAB1" {
+		t.Errorf("Value: want ETAB1, got %q", got)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, query := range []string{"", "93", "9A0$a", "930$", "930[$5", "930[$5=a", "930[x=1]",
+		"930[ind1]", "930[ind1^=1]", `930[$5="a]`, "930[$5~=(]", "930$a/", "930$a/5-2", "930 $a"} {
+		_, err := Compile(query)
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("Compile(%q): want QueryError, got %v", query, err)
+		}
+	}
+}
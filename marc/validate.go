@@ -0,0 +1,262 @@
+package marc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FindingKind is the kind of structural problem found by a Dictionary.
+type FindingKind string
+
+const (
+	FindingUnknownFormat    FindingKind = "unknown_format"
+	FindingInvalidLeader    FindingKind = "invalid_leader"
+	FindingMissingField     FindingKind = "missing_field"
+	FindingRepeatedField    FindingKind = "repeated_field"
+	FindingUnknownField     FindingKind = "unknown_field"
+	FindingFieldType        FindingKind = "field_type"
+	FindingInvalidIndicator FindingKind = "invalid_indicator"
+	FindingEmptyField       FindingKind = "empty_field"
+	FindingUnknownSubfield  FindingKind = "unknown_subfield"
+	FindingMissingSubfield  FindingKind = "missing_subfield"
+	FindingRepeatedSubfield FindingKind = "repeated_subfield"
+)
+
+// Finding is a structural problem of a record. Occurrence is the occurrence of
+// the field among the fields of the same tag, from 0; it is 0 for a missing
+// field. Code is the subfield code, or "ind1" or "ind2", when relevant.
+type Finding struct {
+	Kind       FindingKind
+	Tag        string
+	Occurrence int
+	Code       string
+	Message    string
+}
+
+func (f Finding) String() string {
+	if f.Tag == "" {
+		return fmt.Sprintf("%s: %s", f.Kind, f.Message)
+	}
+	return fmt.Sprintf("%s[%d]: %s: %s", f.Tag, f.Occurrence, f.Kind, f.Message)
+}
+
+// FieldSpec describes a field of a Dictionary. Indicators and subfield codes
+// are given as strings of allowed characters, a space standing for a blank
+// indicator (also written "#" by SUDOC); an empty string allows any value.
+type FieldSpec struct {
+	Control    bool
+	Mandatory  bool
+	Repeatable bool
+	Ind1, Ind2 string
+	Subfields  string
+	// Required are the codes of the mandatory subfields, NonRepeatable those
+	// of the subfields which may appear once.
+	Required      string
+	NonRepeatable string
+}
+
+// Dictionary gives the structure of the fields of a MARC format.
+type Dictionary struct {
+	Format Format
+	Fields map[string]FieldSpec
+	// Strict reports the fields missing from the dictionary, except local
+	// fields (9XX and X9X).
+	Strict bool
+}
+
+// Validate checks a record against the dictionary of its format, UNIMARC or
+// MARC21.
+func Validate(r *Record) []Finding {
+	switch r.Format() {
+	case FormatUNIMARC:
+		return UNIMARCDictionary.Validate(r)
+	case FormatMARC21:
+		return MARC21Dictionary.Validate(r)
+	}
+	return []Finding{{Kind: FindingUnknownFormat, Message: "neither UNIMARC nor MARC21"}}
+}
+
+// Validate checks the leader, the repeatability and type of the fields, their
+// indicators and subfields.
+func (d *Dictionary) Validate(r *Record) []Finding {
+	var findings []Finding
+	if len(r.Leader) != leaderLength {
+		findings = append(findings, Finding{Kind: FindingInvalidLeader,
+			Message: fmt.Sprintf("leader has %d characters, want %d", len(r.Leader), leaderLength)})
+	}
+
+	counts := make(map[string]int)
+	for _, field := range r.Controlfields {
+		occ := counts[field.Tag]
+		counts[field.Tag]++
+		spec, ok := d.spec(field.Tag, occ, &findings)
+		if !ok {
+			continue
+		}
+		if !spec.Control {
+			findings = append(findings, Finding{Kind: FindingFieldType, Tag: field.Tag, Occurrence: occ,
+				Message: "control field, want data field"})
+		}
+	}
+	for i := range r.Datafields {
+		field := &r.Datafields[i]
+		occ := counts[field.Tag]
+		counts[field.Tag]++
+		spec, ok := d.spec(field.Tag, occ, &findings)
+		if !ok {
+			continue
+		}
+		if spec.Control {
+			findings = append(findings, Finding{Kind: FindingFieldType, Tag: field.Tag, Occurrence: occ,
+				Message: "data field, want control field"})
+			continue
+		}
+		findings = append(findings, spec.validateDatafield(field, occ)...)
+	}
+
+	var missing []string
+	for tag, spec := range d.Fields {
+		if spec.Mandatory && counts[tag] == 0 {
+			missing = append(missing, tag)
+		}
+	}
+	sort.Strings(missing)
+	for _, tag := range missing {
+		findings = append(findings, Finding{Kind: FindingMissingField, Tag: tag, Message: "mandatory field is missing"})
+	}
+	return findings
+}
+
+// spec returns the specification of a field, reporting the repeated and
+// unknown fields.
+func (d *Dictionary) spec(tag string, occ int, findings *[]Finding) (FieldSpec, bool) {
+	spec, ok := d.Fields[tag]
+	if !ok {
+		if d.Strict && !isLocalTag(tag) {
+			*findings = append(*findings, Finding{Kind: FindingUnknownField, Tag: tag, Occurrence: occ,
+				Message: "field is not defined"})
+		}
+		return spec, false
+	}
+	if occ == 1 && !spec.Repeatable {
+		*findings = append(*findings, Finding{Kind: FindingRepeatedField, Tag: tag, Occurrence: occ,
+			Message: "field is not repeatable"})
+	}
+	return spec, true
+}
+
+func (spec FieldSpec) validateDatafield(field *Datafield, occ int) []Finding {
+	var findings []Finding
+	for i, ind := range []struct{ value, allowed string }{{field.Ind1, spec.Ind1}, {field.Ind2, spec.Ind2}} {
+		value := ind.value
+		if len(value) <= 1 {
+			value = blankIndicator(value)
+		}
+		if ind.allowed != "" && (len(value) != 1 || !strings.Contains(ind.allowed, value)) {
+			findings = append(findings, Finding{Kind: FindingInvalidIndicator, Tag: field.Tag, Occurrence: occ,
+				Code: fmt.Sprintf("ind%d", i+1), Message: fmt.Sprintf("invalid indicator %q", value)})
+		}
+	}
+	if len(field.Subfields) == 0 {
+		findings = append(findings, Finding{Kind: FindingEmptyField, Tag: field.Tag, Occurrence: occ,
+			Message: "field has no subfield"})
+		return findings
+	}
+
+	counts := make(map[string]int)
+	for _, sub := range field.Subfields {
+		counts[sub.Code]++
+		if spec.Subfields != "" && (len(sub.Code) != 1 || !strings.Contains(spec.Subfields, sub.Code)) {
+			findings = append(findings, Finding{Kind: FindingUnknownSubfield, Tag: field.Tag, Occurrence: occ,
+				Code: sub.Code, Message: fmt.Sprintf("subfield $%s is not defined", sub.Code)})
+		}
+		if counts[sub.Code] == 2 && strings.Contains(spec.NonRepeatable, sub.Code) {
+			findings = append(findings, Finding{Kind: FindingRepeatedSubfield, Tag: field.Tag, Occurrence: occ,
+				Code: sub.Code, Message: fmt.Sprintf("subfield $%s is not repeatable", sub.Code)})
+		}
+	}
+	for _, code := range spec.Required {
+		if counts[string(code)] == 0 {
+			findings = append(findings, Finding{Kind: FindingMissingSubfield, Tag: field.Tag, Occurrence: occ,
+				Code: string(code), Message: fmt.Sprintf("mandatory subfield $%c is missing", code)})
+		}
+	}
+	return findings
+}
+
+// isLocalTag reports whether a tag is reserved for local use: 9XX or X9X.
+func isLocalTag(tag string) bool {
+	return len(tag) == 3 && (tag[0] == '9' || tag[1] == '9')
+}
+
+// UNIMARCDictionary describes the main UNIMARC bibliographic fields, and the
+// SUDOC 930 holdings field.
+var UNIMARCDictionary = &Dictionary{
+	Format: FormatUNIMARC,
+	Fields: map[string]FieldSpec{
+		"001": {Control: true, Mandatory: true},
+		"003": {Control: true},
+		"005": {Control: true},
+		"010": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "abdz", NonRepeatable: "abd"},
+		"011": {Repeatable: true, Subfields: "abdfgyz", NonRepeatable: "abdf"},
+		"035": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "az", NonRepeatable: "a"},
+		"073": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "abdz", NonRepeatable: "abd"},
+		"100": {Mandatory: true, Ind1: " ", Ind2: " ", Subfields: "a", Required: "a", NonRepeatable: "a"},
+		"101": {Ind1: "012", Ind2: " ", Subfields: "abcdefghijz"},
+		"102": {Ind1: " ", Ind2: " ", Subfields: "abc2"},
+		"105": {Ind1: " ", Ind2: " ", Subfields: "a", Required: "a", NonRepeatable: "a"},
+		"106": {Ind1: " ", Ind2: " ", Subfields: "a", Required: "a", NonRepeatable: "a"},
+		"181": {Repeatable: true, Subfields: "6Pabc2"},
+		"182": {Repeatable: true, Subfields: "6Pac2"},
+		"183": {Repeatable: true, Subfields: "6Pa2"},
+		"200": {Mandatory: true, Ind1: "01", Ind2: " ", Subfields: "abcdefghivz5", Required: "a"},
+		"205": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "abdfg", NonRepeatable: "a"},
+		"210": {Repeatable: true, Subfields: "abcdefghrs"},
+		"214": {Repeatable: true, Subfields: "abcdrs"},
+		"215": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "acde"},
+		"225": {Repeatable: true, Ind1: "012", Ind2: " ", Subfields: "adefhivxz"},
+		"300": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "a", NonRepeatable: "a"},
+		"330": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "a", NonRepeatable: "a"},
+		"606": {Repeatable: true, Subfields: "3abjxyz2", NonRepeatable: "3a2"},
+		"676": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "av3", NonRepeatable: "av"},
+		"700": {Ind1: " ", Ind2: "01", Subfields: "34abcdfgp", NonRepeatable: "3a"},
+		"701": {Repeatable: true, Ind1: " ", Ind2: "01", Subfields: "34abcdfgp", NonRepeatable: "3a"},
+		"702": {Repeatable: true, Ind1: " ", Ind2: "01", Subfields: "345abcdfgp", NonRepeatable: "3a"},
+		"801": {Repeatable: true, Ind1: " ", Ind2: "0123", Subfields: "abcg2", Required: "abc", NonRepeatable: "abc"},
+		"930": {Repeatable: true, Required: "5", NonRepeatable: "5c"},
+	},
+}
+
+// MARC21Dictionary describes the main MARC21 bibliographic fields.
+var MARC21Dictionary = &Dictionary{
+	Format: FormatMARC21,
+	Fields: map[string]FieldSpec{
+		"001": {Control: true, Mandatory: true},
+		"003": {Control: true},
+		"005": {Control: true},
+		"006": {Control: true, Repeatable: true},
+		"007": {Control: true, Repeatable: true},
+		"008": {Control: true, Mandatory: true},
+		"020": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "acqz68", NonRepeatable: "ac"},
+		"022": {Repeatable: true, Ind1: " 01", Ind2: " ", Subfields: "almyz268", NonRepeatable: "al2"},
+		"035": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "az68", NonRepeatable: "a"},
+		"040": {Ind1: " ", Ind2: " ", Subfields: "abcde68", Required: "a", NonRepeatable: "abc"},
+		"041": {Repeatable: true, Ind1: " 01", Ind2: " 7", Subfields: "abdefghjkmnpqrt268"},
+		"100": {Ind1: "013", Ind2: " ", Subfields: "abcdefgjklnpqtu0124568", Required: "a", NonRepeatable: "abdfgklnpqtu"},
+		"245": {Mandatory: true, Ind1: "01", Ind2: "0123456789", Subfields: "abcfghknps68", NonRepeatable: "abcfh"},
+		"246": {Repeatable: true, Ind1: "0123", Ind2: " 012345678", Subfields: "abfghinp5678", NonRepeatable: "abfh"},
+		"250": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "ab3678", NonRepeatable: "ab3"},
+		"260": {Repeatable: true, Ind1: " 23", Ind2: " ", Subfields: "abcefg3678"},
+		"264": {Repeatable: true, Ind1: " 23", Ind2: "01234", Subfields: "abc3678"},
+		"300": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "abcefg3678"},
+		"336": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "ab023678", NonRepeatable: "23"},
+		"490": {Repeatable: true, Ind1: "01", Ind2: " ", Subfields: "alvxy35678"},
+		"500": {Repeatable: true, Ind1: " ", Ind2: " ", Subfields: "a35678", Required: "a", NonRepeatable: "a3"},
+		"520": {Repeatable: true, Ind1: " 012348", Ind2: " ", Subfields: "abcu23678", NonRepeatable: "ab3"},
+		"650": {Repeatable: true, Ind1: " 012", Ind2: "01234567", Subfields: "abcdegvxyz0123468", NonRepeatable: "ad"},
+		"700": {Repeatable: true, Ind1: "013", Ind2: " 2", Subfields: "abcdefghijklmnopqrstux0123456789"},
+		"710": {Repeatable: true, Ind1: "012", Ind2: " 2", Subfields: "abcdefghiklmnoprstux0123456789"},
+		"856": {Repeatable: true, Ind1: " 012347", Ind2: " 01278", Subfields: "abcdfhlmnopqrstuvwxyz2367"},
+	},
+}
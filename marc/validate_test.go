@@ -0,0 +1,74 @@
+package marc
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	sub := func(code, value string) Subfield { return Subfield{Code: code, Value: value} }
+	record := Record{
+		Leader:        "     nam0 22        450 ",
+		Controlfields: []Controlfield{{Tag: "001", Value: "123456789"}, {Tag: "100", Value: "x"}},
+		Datafields: []Datafield{
+			{Tag: "200", Ind1: "1", Ind2: " ", Subfields: []Subfield{sub("a", "Orlando")}},
+			{Tag: "200", Ind1: "2", Subfields: []Subfield{sub("a", "Orlando"), sub("k", "?")}},
+			{Tag: "801", Ind1: "#", Ind2: "3", Subfields: []Subfield{sub("a", "FR"), sub("b", "AUC"), sub("b", "ABES")}},
+			{Tag: "930", Subfields: []Subfield{sub("a", "823 WOO")}},
+			{Tag: "930", Subfields: []Subfield{sub("5", "100000001:EX1"), sub("5", "100000001:EX2")}},
+			{Tag: "999"},
+		},
+	}
+
+	want := []string{
+		"100[0]: field_type: control field, want data field",
+		"200[1]: repeated_field: field is not repeatable",
+		"200[1]: invalid_indicator: invalid indicator \"2\"",
+		"200[1]: unknown_subfield: subfield $k is not defined",
+		"801[0]: repeated_subfield: subfield $b is not repeatable",
+		"801[0]: missing_subfield: mandatory subfield $c is missing",
+		"930[0]: missing_subfield: mandatory subfield $5 is missing",
+		"930[1]: repeated_subfield: subfield $5 is not repeatable",
+	}
+	var got []string
+	for _, f := range Validate(&record) {
+		got = append(got, f.String())
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("want\n%q\ngot\n%q", want, got)
+	}
+}
+
+func TestValidateStrict(t *testing.T) {
+	record := Record{
+		Leader: "00000cam a2200000 a 4500",
+		Controlfields: []Controlfield{
+			{Tag: "001", Value: "1"},
+			{Tag: "008", Value: "130626s1928    xxk           000 1 eng d"},
+		},
+		Datafields: []Datafield{
+			{Tag: "245", Ind1: "1", Ind2: "0", Subfields: []Subfield{{Code: "a", Value: "Orlando"}}},
+			{Tag: "590", Ind1: " ", Ind2: " ", Subfields: []Subfield{{Code: "a", Value: "local"}}},
+			{Tag: "883", Subfields: []Subfield{{Code: "a", Value: "x"}}},
+		},
+	}
+	if findings := Validate(&record); len(findings) != 0 {
+		t.Errorf("want no finding, got %v", findings)
+	}
+	strict := *MARC21Dictionary
+	strict.Strict = true
+	findings := strict.Validate(&record)
+	if len(findings) != 1 || findings[0].Kind != FindingUnknownField || findings[0].Tag != "883" {
+		t.Errorf("want an unknown 883 field, got %v", findings)
+	}
+
+	if findings := Validate(&Record{}); len(findings) != 1 || findings[0].Kind != FindingUnknownFormat {
+		t.Errorf("want an unknown format, got %v", findings)
+	}
+	record.Controlfields = record.Controlfields[1:]
+	record.Leader = "short"
+	findings = Validate(&record)
+	if len(findings) != 2 || findings[0].Kind != FindingInvalidLeader || findings[1].Kind != FindingMissingField {
+		t.Errorf("want an invalid leader and a missing 001, got %v", findings)
+	}
+}
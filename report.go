@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"casl/controller"
+	"casl/history"
+)
+
+var reportCmd = &command{
+	name:  "report",
+	args:  "[results_file]",
+	short: "render again the results of a run, from a results file or the history database",
+	setup: func(fs *flag.FlagSet) func(args []string, stdout, stderr io.Writer) error {
+		opts := reportOptions{config: configFlag(fs)}
+		opts.output = outputFlags(fs)
+		opts.history = fs.String("history", "", "SQLite database where runs are recorded")
+		opts.run = fs.Int64("run", 0, "run to render, from the history database (default: last run)")
+		return func(args []string, stdout, stderr io.Writer) error { return report(opts, args, stdout, stderr) }
+	},
+}
+
+type reportOptions struct {
+	config  *string
+	output  outputOptions
+	history *string
+	run     *int64
+}
+
+func report(opts reportOptions, args []string, stdout, stderr io.Writer) error {
+	if err := opts.output.validate(); err != nil {
+		return err
+	}
+	if (*opts.history == "") == (len(args) == 0) || len(args) > 1 {
+		return fmt.Errorf("want either a results file or -history: %w", errUsage)
+	}
+
+	var results []controller.Summary
+	var err error
+	if *opts.history != "" {
+		results, err = historyResults(*opts.history, *opts.run)
+	} else {
+		results, err = controller.ReadResults(args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	// The configuration only provides library labels and Alma links.
+	ctrl, err := controller.NewOfflineController(*opts.config)
+	if err != nil {
+		newLogger(stderr).Printf("%v: rendering without configuration", err)
+		ctrl = controller.NewEmptyController()
+	}
+	filename, err := opts.output.write(&ctrl, results)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%d anomalie(s) : %s\n", len(results), filename)
+	return nil
+}
+
+// historyResults reads the anomalies of a run; id 0 is the last run.
+func historyResults(path string, id int64) ([]controller.Summary, error) {
+	// Do not create an empty database.
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	store, err := history.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	if id == 0 {
+		if id, err = store.LastRunID(); err != nil {
+			return nil, err
+		}
+	}
+	results, err := store.Anomalies(id)
+	if err != nil {
+		return nil, fmt.Errorf("run %d: %w", id, err)
+	}
+	return results, nil
+}
@@ -2,6 +2,7 @@
 package requests
 
 import (
+	"context"
 	"errors"
 	"io"
 	"log"
@@ -23,6 +24,26 @@ type Fetcher interface {
 	Fetch(url string) ([]byte, error)
 }
 
+// ContextFetcher is a Fetcher whose requests can be canceled, or given a
+// deadline, by a context.
+type ContextFetcher interface {
+	Fetcher
+	FetchContext(ctx context.Context, url string) ([]byte, error)
+}
+
+// FetchContext fetches the URL with the context if the fetcher is a
+// ContextFetcher. Other fetchers are not called once the context is done, but
+// their requests cannot be interrupted.
+func FetchContext(ctx context.Context, f Fetcher, url string) ([]byte, error) {
+	if cf, ok := f.(ContextFetcher); ok {
+		return cf.FetchContext(ctx, url)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.Fetch(url)
+}
+
 type HttpFetcher struct {
 	client *http.Client
 }
@@ -40,8 +61,21 @@ func NewHttpFetch(client *http.Client) Fetcher {
 // Fetch returns the xml record corresponding to the given URL, or nil if
 // unsucessful.
 func (f HttpFetcher) Fetch(url string) ([]byte, error) {
-	resp, err := f.client.Get(url)
+	return f.FetchContext(context.Background(), url)
+}
+
+// FetchContext is Fetch with a context: the request is interrupted, and the
+// error of the context returned, when the context is done.
+func (f HttpFetcher) FetchContext(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return []byte{}, err
+	}
+	resp, err := f.client.Do(req)
 	if err != nil {
+		if ctx.Err() != nil {
+			return []byte{}, ctx.Err()
+		}
 		// if request time out, just ignore
 		// TODO: delay and request again
 		// TODO: handle other url.errors
@@ -0,0 +1,44 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type plainFetcher struct{ calls int }
+
+func (f *plainFetcher) Fetch(url string) ([]byte, error) {
+	f.calls++
+	return []byte("ok"), nil
+}
+
+func TestFetchContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<record/>"))
+	}))
+	defer server.Close()
+
+	fetcher := NewHttpFetch(nil)
+	data, err := FetchContext(context.Background(), fetcher, server.URL)
+	if err != nil || string(data) != "<record/>" {
+		t.Errorf("got %q, %v", data, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FetchContext(ctx, fetcher, server.URL); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+
+	// A fetcher without context is not called once the context is done.
+	plain := &plainFetcher{}
+	if _, err := FetchContext(ctx, plain, server.URL); !errors.Is(err, context.Canceled) || plain.calls != 0 {
+		t.Errorf("want context.Canceled without call, got %v after %d call(s)", err, plain.calls)
+	}
+	if _, err := FetchContext(context.Background(), plain, server.URL); err != nil || plain.calls != 1 {
+		t.Errorf("want 1 call, got %v after %d call(s)", err, plain.calls)
+	}
+}
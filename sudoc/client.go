@@ -5,15 +5,18 @@ import (
 	"casl/entities"
 	"casl/marc"
 	"casl/requests"
+	"context"
 	"errors"
 	"fmt"
-	"slices"
 	"strings"
 )
 
 // SudocClient represents the main object to interact with.
 type SudocClient struct {
-	rcrs    map[string]library
+	rcrs map[string]library
+	// others are the RCRs of untracked ILNs found in locations, resolved with
+	// the rcr2iln service.
+	others  map[string]library
 	stats   stats
 	fetcher requests.Fetcher
 }
@@ -27,17 +30,24 @@ type library struct {
 
 type stats struct {
 	iln2rcr int
+	rcr2iln int
 	marcxml int
 }
 
 const (
 	DEFAULT_BASE_URL = "https://www.sudoc.fr/"
 	ILN2RCR_URL      = "https://www.idref.fr/services/iln2rcr/"
+	RCR2ILN_URL      = "https://www.idref.fr/services/rcr2iln/"
 )
 
 // NewSudocClient provides a SUDOC client including RCR->library mappings built
 // from the iln2rcr API.
 func NewSudocClient(ilns []string, fetcher requests.Fetcher) (*SudocClient, error) {
+	return NewSudocClientContext(context.Background(), ilns, fetcher)
+}
+
+// NewSudocClientContext is NewSudocClient with a context.
+func NewSudocClientContext(ctx context.Context, ilns []string, fetcher requests.Fetcher) (*SudocClient, error) {
 	if ilns == nil || len(ilns) == 0 {
 		return nil, errors.New("NewSudocClient: empty or nil list of ILNs")
 	}
@@ -48,7 +58,7 @@ func NewSudocClient(ilns []string, fetcher requests.Fetcher) (*SudocClient, erro
 	var client SudocClient
 	client.fetcher = fetcher
 
-	rcrs, err := client.getRCRs(ilns)
+	rcrs, err := client.getRCRs(ctx, ilns)
 	if err != nil {
 		return nil, fmt.Errorf("NewSudocClient: mapping failed: %w", err)
 	}
@@ -60,14 +70,19 @@ func NewSudocClient(ilns []string, fetcher requests.Fetcher) (*SudocClient, erro
 // from the unimarc2marcxml API. Only the locations regarding the RCRs of
 // interest, given as a second argument, are provided.
 func (sc *SudocClient) GetFilteredLocations(ppn string, rcrs []string) ([]*entities.SudocLocation, error) {
+	return sc.GetFilteredLocationsContext(context.Background(), ppn, rcrs)
+}
+
+// GetFilteredLocationsContext is GetFilteredLocations with a context.
+func (sc *SudocClient) GetFilteredLocationsContext(ctx context.Context, ppn string, rcrs []string) ([]*entities.SudocLocation, error) {
 	var filtered []*entities.SudocLocation
-	locations, err := sc.GetLocations(ppn)
+	locations, err := sc.GetLocationsContext(ctx, ppn)
 	if err != nil {
 		return filtered, err
 	}
 
 	for _, location := range locations {
-		if slices.Contains(rcrs, location.RCR) {
+		if ok, _ := location.IsFollowed(rcrs); ok {
 			filtered = append(filtered, location)
 		}
 	}
@@ -77,17 +92,27 @@ func (sc *SudocClient) GetFilteredLocations(ppn string, rcrs []string) ([]*entit
 // GetLocations gets all the SUDOC locations of a given PPN, from the
 // unimarc2marcxml API, filled with data from client's RCR mappings.
 func (sc *SudocClient) GetLocations(ppn string) ([]*entities.SudocLocation, error) {
-	var locs []*entities.SudocLocation
-	sc.stats.marcxml += 1
-	data, err := sc.fetcher.Fetch(DEFAULT_BASE_URL + ppn + ".xml")
-	if err != nil {
-		return locs, fmt.Errorf("ppn %s: %w\n", ppn, err)
-	}
-	marcRecord, err := marc.NewRecord(data)
+	return sc.GetLocationsContext(context.Background(), ppn)
+}
+
+// GetLocationsContext is GetLocations with a context.
+func (sc *SudocClient) GetLocationsContext(ctx context.Context, ppn string) ([]*entities.SudocLocation, error) {
+	marcRecord, err := sc.GetRecordContext(ctx, ppn)
 	if err != nil {
-		return locs, err
+		return nil, err
 	}
+	return sc.RecordLocationsContext(ctx, ppn, marcRecord)
+}
 
+// RecordLocations gets the SUDOC locations of the record of a PPN, already
+// fetched with GetRecord, filled with data from client's RCR mappings.
+func (sc *SudocClient) RecordLocations(ppn string, marcRecord *marc.Record) ([]*entities.SudocLocation, error) {
+	return sc.RecordLocationsContext(context.Background(), ppn, marcRecord)
+}
+
+// RecordLocationsContext is RecordLocations with a context.
+func (sc *SudocClient) RecordLocationsContext(ctx context.Context, ppn string, marcRecord *marc.Record) ([]*entities.SudocLocation, error) {
+	var locs []*entities.SudocLocation
 	for _, field := range marcRecord.GetField("930") {
 		rcr := field.GetValue("5")
 		if len(rcr) != 1 {
@@ -100,30 +125,98 @@ func (sc *SudocClient) GetLocations(ppn string) ([]*entities.SudocLocation, erro
 		}
 
 		var location entities.SudocLocation
-		location.RCR = strings.Split(rcr[0], ":")[0]
+		location.RCR, location.EPN, _ = strings.Cut(rcr[0], ":")
 		if len(sublocation) == 1 {
 			location.Sublocation = sublocation[0]
 		}
+		if callNumber := field.GetValue("a"); len(callNumber) > 0 {
+			location.CallNumber = callNumber[0]
+		}
 
 		// Add informations from the RCR mappings
-		location.ILN = sc.rcrs[location.RCR].iln
-		location.Name = sc.rcrs[location.RCR].name
+		lib, err := sc.library(ctx, location.RCR)
+		if err != nil {
+			return locs, fmt.Errorf("ppn %s: RCR %s: %w", ppn, location.RCR, err)
+		}
+		location.ILN = lib.iln
+		location.Name = lib.name
 		locs = append(locs, &location)
 	}
 	return locs, nil
 }
 
+// GetRecord gets the bibliographic record of a given PPN from the
+// unimarc2marcxml API.
+func (sc *SudocClient) GetRecord(ppn string) (*marc.Record, error) {
+	return sc.GetRecordContext(context.Background(), ppn)
+}
+
+// GetRecordContext is GetRecord with a context.
+func (sc *SudocClient) GetRecordContext(ctx context.Context, ppn string) (*marc.Record, error) {
+	sc.stats.marcxml += 1
+	data, err := requests.FetchContext(ctx, sc.fetcher, DEFAULT_BASE_URL+ppn+".xml")
+	if err != nil {
+		return nil, fmt.Errorf("ppn %s: %w\n", ppn, err)
+	}
+	return marc.NewRecord(data)
+}
+
+// ErrUnknownRCR is returned by GetLibrary for an RCR which does not exist.
+var ErrUnknownRCR = errors.New("unknown RCR")
+
+// GetLibrary returns the ILN and the short name of the library of an RCR,
+// resolved with the rcr2iln service if the RCR is not in a tracked ILN. It
+// returns ErrUnknownRCR for an unknown RCR, and the error of the service if
+// the RCR cannot be looked up.
+func (sc *SudocClient) GetLibrary(rcr string) (iln, name string, err error) {
+	return sc.GetLibraryContext(context.Background(), rcr)
+}
+
+// GetLibraryContext is GetLibrary with a context.
+func (sc *SudocClient) GetLibraryContext(ctx context.Context, rcr string) (iln, name string, err error) {
+	lib, err := sc.library(ctx, rcr)
+	if err == nil && lib.iln == "" {
+		err = ErrUnknownRCR
+	}
+	return lib.iln, lib.name, err
+}
+
+// library returns the library of an RCR. RCRs of untracked ILNs are resolved
+// once with the rcr2iln service; an unknown RCR has a library with its RCR
+// only. A failed lookup is not kept, to be tried again.
+func (sc *SudocClient) library(ctx context.Context, rcr string) (library, error) {
+	if lib, ok := sc.rcrs[rcr]; ok {
+		return lib, nil
+	}
+	if lib, ok := sc.others[rcr]; ok {
+		return lib, nil
+	}
+	lib, err := sc.getILN(ctx, rcr)
+	if errors.Is(err, ErrUnknownRCR) {
+		lib = library{rcr: rcr}
+	} else if err != nil {
+		return library{rcr: rcr}, err
+	}
+	if sc.others == nil {
+		sc.others = make(map[string]library)
+	}
+	sc.others[rcr] = lib
+	return lib, nil
+}
+
 // Stats returns numbers of requests made by the client to the service named
-// by the argument ("iln2rcr", "marcxml", "total").
+// by the argument ("iln2rcr", "rcr2iln", "marcxml", "total").
 // TODO: provide a better way to select the stat than by string
 func (sc *SudocClient) Stats(t string) int {
 	switch t {
 	case "iln2rcr":
 		return sc.stats.iln2rcr
+	case "rcr2iln":
+		return sc.stats.rcr2iln
 	case "marcxml":
 		return sc.stats.marcxml
 	case "total":
-		return sc.stats.iln2rcr + sc.stats.marcxml
+		return sc.stats.iln2rcr + sc.stats.rcr2iln + sc.stats.marcxml
 	default:
 		return sc.Stats("total")
 	}
@@ -139,10 +232,10 @@ func (sc *SudocClient) GetFollowedRCRs() []string {
 }
 
 // getRCRs builds the map RCR->Library from the iln2rcr service.
-func (sc *SudocClient) getRCRs(ilns []string) (map[string]library, error) {
+func (sc *SudocClient) getRCRs(ctx context.Context, ilns []string) (map[string]library, error) {
 	url := ILN2RCR_URL + strings.Join(ilns, ",")
 	sc.stats.iln2rcr += 1
-	data, err := sc.fetcher.Fetch(url)
+	data, err := requests.FetchContext(ctx, sc.fetcher, url)
 	if err != nil {
 		return nil, fmt.Errorf("getRCRs: iln2rcr failed: %w", err)
 	}
@@ -152,3 +245,17 @@ func (sc *SudocClient) getRCRs(ilns []string) (map[string]library, error) {
 	}
 	return result, nil
 }
+
+// getILN gets the ILN and the name of a library from the rcr2iln service.
+func (sc *SudocClient) getILN(ctx context.Context, rcr string) (library, error) {
+	sc.stats.rcr2iln += 1
+	data, err := requests.FetchContext(ctx, sc.fetcher, RCR2ILN_URL+rcr)
+	if err != nil {
+		return library{}, fmt.Errorf("getILN: rcr2iln failed: %w", err)
+	}
+	lib, err := decodeILN(data)
+	if err != nil {
+		return library{}, fmt.Errorf("getILN: decoding XML failed: %w", err)
+	}
+	return lib, nil
+}
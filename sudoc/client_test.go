@@ -3,9 +3,12 @@ package sudoc
 import (
 	"casl/entities"
 	"casl/requests"
+	"context"
+	"errors"
 	"math/rand"
 	"os"
 	"reflect"
+	"slices"
 	"testing"
 )
 
@@ -37,6 +40,26 @@ func (m mockHttpFetcher) Fetch(url string) ([]byte, error) {
 			return nil, err
 		}
 		return data, nil
+	case DEFAULT_BASE_URL + "ppn_other_iln" + ".xml":
+		data, err := os.ReadFile("testdata/marcxml_other_iln.xml")
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	case RCR2ILN_URL + "900000001":
+		data, err := os.ReadFile("testdata/rcr2iln_not_found.xml")
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	case RCR2ILN_URL + "800000001":
+		return nil, errors.New("503")
+	case RCR2ILN_URL + "300000001":
+		data, err := os.ReadFile("testdata/rcr2iln.xml")
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
 	default:
 		return nil, nil
 	}
@@ -91,10 +114,10 @@ func TestGetLocations(t *testing.T) {
 
 	var empty []*entities.SudocLocation
 	locations := []*entities.SudocLocation{
-		{ILN: "1", RCR: "100000001", Name: "UNIV-1.1", Sublocation: "SUB1"},
-		{ILN: "2", RCR: "200000001", Name: "UNIV-2.1"},
-		{ILN: "2", RCR: "200000001", Name: "UNIV-2.1"},
-		{ILN: "2", RCR: "200000002", Name: "UNIV-2.2"},
+		{ILN: "1", RCR: "100000001", EPN: "EX1", Name: "UNIV-1.1", Sublocation: "SUB1", CallNumber: "823.9 WOO"},
+		{ILN: "2", RCR: "200000001", EPN: "EX2", Name: "UNIV-2.1"},
+		{ILN: "2", RCR: "200000001", EPN: "EX3", Name: "UNIV-2.1"},
+		{ILN: "2", RCR: "200000002", EPN: "EX4", Name: "UNIV-2.2"},
 	}
 	tests := []struct {
 		name  string
@@ -125,10 +148,10 @@ func TestGetFilteredLocations(t *testing.T) {
 	}
 	var empty []*entities.SudocLocation
 	locations := []*entities.SudocLocation{
-		{ILN: "1", RCR: "100000001", Name: "UNIV-1.1", Sublocation: "SUB1"},
-		{ILN: "2", RCR: "200000001", Name: "UNIV-2.1"},
-		{ILN: "2", RCR: "200000001", Name: "UNIV-2.1"},
-		{ILN: "2", RCR: "200000002", Name: "UNIV-2.2"},
+		{ILN: "1", RCR: "100000001", EPN: "EX1", Name: "UNIV-1.1", Sublocation: "SUB1", CallNumber: "823.9 WOO"},
+		{ILN: "2", RCR: "200000001", EPN: "EX2", Name: "UNIV-2.1"},
+		{ILN: "2", RCR: "200000001", EPN: "EX3", Name: "UNIV-2.1"},
+		{ILN: "2", RCR: "200000002", EPN: "EX4", Name: "UNIV-2.2"},
 	}
 	tests := []struct {
 		name string
@@ -140,7 +163,7 @@ func TestGetFilteredLocations(t *testing.T) {
 		{"no locations", "ppn_no_locations", []string{"100000001"}, empty},
 		{"all locations", "ppn", []string{"100000001", "200000001", "200000002"}, locations},
 		{"rcr_200000002", "ppn", []string{"200000002"}, []*entities.SudocLocation{
-			{ILN: "2", RCR: "200000002", Name: "UNIV-2.2"}}},
+			{ILN: "2", RCR: "200000002", EPN: "EX4", Name: "UNIV-2.2"}}},
 	}
 
 	for _, test := range tests {
@@ -174,7 +197,7 @@ func TestGetRCRs(t *testing.T) {
 	want["200000002"] = library{"2", "200000002", "UNIV-2.2"}
 	want["200000003"] = library{"2", "200000003", "UNIV-2.3"}
 
-	got, err := sc_ok.getRCRs(input_ok)
+	got, err := sc_ok.getRCRs(context.Background(), input_ok)
 	if err != nil {
 		t.Fatalf("want %v, got %v", want, err)
 	}
@@ -195,7 +218,7 @@ func TestStats(t *testing.T) {
 
 	n := rand.Intn(100) + 10
 	for i := 0; i < n; i++ {
-		sc.getRCRs([]string{"1", "2"})
+		sc.getRCRs(context.Background(), []string{"1", "2"})
 		sc.GetLocations("")
 	}
 	iln2rcr = sc.Stats("iln2rcr")
@@ -206,3 +229,85 @@ func TestStats(t *testing.T) {
 			iln2rcr, marcxml, total, n+1, n, 2*n+1)
 	}
 }
+
+func TestGetRecord(t *testing.T) {
+	sc, _ := NewSudocClient([]string{"1", "2"}, mockHttpFetcher{})
+	record, err := sc.GetRecord("ppn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(record.GetField("930")) == 0 {
+		t.Error("want 930 fields")
+	}
+	locs, err := sc.RecordLocations("ppn", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs) != len(record.GetField("930")) {
+		t.Errorf("want a location per 930 field, got %v", locs)
+	}
+	if n := sc.Stats("marcxml"); n != 1 {
+		t.Errorf("want 1 request for the record and its locations, got %d", n)
+	}
+	// The client keeps no record: a PPN fetched again is requested again.
+	if _, err := sc.GetRecord("ppn"); err != nil {
+		t.Fatal(err)
+	}
+	if n := sc.Stats("marcxml"); n != 2 {
+		t.Errorf("want 2 requests, got %d", n)
+	}
+}
+
+func TestGetLocationsContext(t *testing.T) {
+	sc, _ := NewSudocClient([]string{"1", "2"}, mockHttpFetcher{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sc.GetLocationsContext(ctx, "ppn"); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	if _, err := sc.GetLocationsContext(context.Background(), "ppn"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUntrackedRCR(t *testing.T) {
+	sc, _ := NewSudocClient([]string{"1", "2"}, mockHttpFetcher{})
+	got, err := sc.GetLocations("ppn_other_iln")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*entities.SudocLocation{
+		{ILN: "1", RCR: "100000001", EPN: "EX1", Name: "UNIV-1.1"},
+		{ILN: "3", RCR: "300000001", EPN: "EX5", Name: "UNIV-3.1"},
+		{ILN: "3", RCR: "300000001", EPN: "EX6", Name: "UNIV-3.1"},
+		{RCR: "900000001", EPN: "EX9"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if n := sc.Stats("rcr2iln"); n != 2 {
+		t.Errorf("want one request per unknown RCR, got %d", n)
+	}
+	if slices.Contains(sc.GetFollowedRCRs(), "300000001") {
+		t.Error("an RCR of an untracked ILN must not be followed")
+	}
+	if iln, name, err := sc.GetLibrary("300000001"); err != nil || iln != "3" || name != "UNIV-3.1" {
+		t.Errorf("want ILN 3 UNIV-3.1, got %q %q %v", iln, name, err)
+	}
+	if _, _, err := sc.GetLibrary("900000001"); !errors.Is(err, ErrUnknownRCR) {
+		t.Errorf("want an unknown RCR, got %v", err)
+	}
+	if _, _, err := sc.GetLibrary("800000001"); err == nil || errors.Is(err, ErrUnknownRCR) {
+		t.Errorf("want the error of rcr2iln, got %v", err)
+	}
+
+	// A failed lookup is returned, and tried again next time.
+	for i := 0; i < 2; i++ {
+		if _, err := sc.library(context.Background(), "800000001"); err == nil {
+			t.Error("want the error of rcr2iln")
+		}
+	}
+	if n := sc.Stats("rcr2iln"); n != 5 {
+		t.Errorf("want failed lookups to be tried again, got %d requests", n)
+	}
+}
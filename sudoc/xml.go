@@ -3,7 +3,7 @@ package sudoc
 import (
 	"encoding/xml"
 	"errors"
-	"log"
+	"fmt"
 )
 
 type iln2rcr_response struct {
@@ -23,12 +23,29 @@ type iln2rcr_library struct {
 	Name    string   `xml:"shortname"`
 }
 
+type rcr2iln_response struct {
+	XMLName xml.Name        `xml:"sudoc"`
+	Queries []rcr2iln_query `xml:"query"`
+}
+
+type rcr2iln_query struct {
+	XMLName   xml.Name          `xml:"query"`
+	RCR       string            `xml:"rcr"`
+	Libraries []rcr2iln_library `xml:"result>library"`
+}
+
+type rcr2iln_library struct {
+	XMLName xml.Name `xml:"library"`
+	ILN     string   `xml:"iln"`
+	Name    string   `xml:"shortname"`
+}
+
 func decodeRCR(data []byte) (map[string]library, error) {
 	mapping := make(map[string]library)
 	var result iln2rcr_response
 	err := xml.Unmarshal(data, &result)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("decodeRCR: %w", err)
 	}
 	// iln not found
 	if len(result.Queries) == 0 {
@@ -41,3 +58,16 @@ func decodeRCR(data []byte) (map[string]library, error) {
 	}
 	return mapping, nil
 }
+
+func decodeILN(data []byte) (library, error) {
+	var result rcr2iln_response
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return library{}, err
+	}
+	// rcr not found
+	if len(result.Queries) == 0 || len(result.Queries[0].Libraries) == 0 {
+		return library{}, ErrUnknownRCR
+	}
+	q := result.Queries[0]
+	return library{q.Libraries[0].ILN, q.RCR, q.Libraries[0].Name}, nil
+}
@@ -35,4 +35,8 @@ func TestDecodeRCR(t *testing.T) {
 	if err == nil {
 		t.Error("want error for 'null xml' response")
 	}
+
+	if _, err := decodeRCR([]byte("<sudoc><query>")); err == nil {
+		t.Error("want error for malformed xml")
+	}
 }
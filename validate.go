@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"casl/controller"
+)
+
+var validateConfigCmd = &command{
+	name:  "validate-config",
+	args:  "",
+	short: "check the configuration file and the files it refers to",
+	setup: func(fs *flag.FlagSet) func(args []string, stdout, stderr io.Writer) error {
+		config := configFlag(fs)
+		return func(args []string, stdout, stderr io.Writer) error {
+			return validateConfig(*config, args, stdout, stderr)
+		}
+	},
+}
+
+func validateConfig(config string, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected arguments: %w", errUsage)
+	}
+	ctrl, err := controller.NewOfflineController(config)
+	if err != nil {
+		return err
+	}
+	if err := reportProblems(stderr, ctrl.ValidateConfig()); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: OK\n", config)
+	return nil
+}